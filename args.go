@@ -1,10 +1,35 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/posener/complete/v2"
+	"golang.org/x/term"
 )
 
+// ArgError is an error of a specific positional argument. It can be returned from the `Set` method
+// of an `ArgsValue` to point at the argument that failed.
+type ArgError struct {
+	// Index is the index of the failed argument in the positional arguments.
+	Index int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ArgError) Error() string { return e.Err.Error() }
+
+func (e *ArgError) Unwrap() error { return e.Err }
+
 // ArgsStr are string positional arguments. If it is created with cap > 0, it will be used to define
 // the number of required arguments.
 //
@@ -23,15 +48,16 @@ import (
 //
 // 	args := make(cmd.ArgsStr, 3)
 // 	root.ArgsVar(&args, "[arg1] [arg2] [arg3]", "list of 3 arguments")
+//
+// To get a list of 1 to 3 arguments:
+//
+// 	var args cmd.ArgsStr
+// 	root.ArgsVar(cmd.ArgsBetween(1, 3, &args), "[arg...]", "list of 1 to 3 arguments")
 type ArgsStr []string
 
 // Set implements the ArgsValue interface.
 func (a *ArgsStr) Set(args []string) error {
-	if cap(*a) > 0 && len(args) != cap(*a) {
-		return fmt.Errorf("required %d positional args, got %v", cap(*a), args)
-	}
-	*a = args
-	return nil
+	return setOf((*[]string)(a), args, "", func(arg string) (string, error) { return arg, nil })
 }
 
 // ArgsInt are int positional arguments. If it is created with cap > 0, it will be used to define
@@ -56,16 +82,397 @@ type ArgsInt []int
 
 // Set implements the ArgsValue interface.
 func (a *ArgsInt) Set(args []string) error {
-	if cap(*a) > 0 && len(args) != cap(*a) {
-		return fmt.Errorf("required %d positional args, got %v", cap(*a), args)
+	return setOf((*[]int)(a), args, "int", strconv.Atoi)
+}
+
+// ArgsFloat are float64 positional arguments. If it is created with cap > 0, it will be used to
+// define the number of required arguments.
+type ArgsFloat []float64
+
+// Set implements the ArgsValue interface.
+func (a *ArgsFloat) Set(args []string) error {
+	return setOf((*[]float64)(a), args, "float", func(arg string) (float64, error) {
+		return strconv.ParseFloat(arg, 64)
+	})
+}
+
+// ArgsBool are bool positional arguments, in the formats that `strconv.ParseBool` accepts. If it is
+// created with cap > 0, it will be used to define the number of required arguments.
+type ArgsBool []bool
+
+// Set implements the ArgsValue interface.
+func (a *ArgsBool) Set(args []string) error {
+	return setOf((*[]bool)(a), args, "bool", strconv.ParseBool)
+}
+
+// ArgsDuration are duration positional arguments, in the format that `time.ParseDuration` accepts.
+// If it is created with cap > 0, it will be used to define the number of required arguments.
+type ArgsDuration []time.Duration
+
+// Set implements the ArgsValue interface.
+func (a *ArgsDuration) Set(args []string) error {
+	return setOf((*[]time.Duration)(a), args, "duration", time.ParseDuration)
+}
+
+// ArgsTime are time positional arguments in a given layout, as in `time.Parse`. If Values is
+// created with cap > 0, it will be used to define the number of required arguments. For example:
+//
+// 	args := cmd.ArgsTime{Layout: "2006-01-02"}
+// 	root.ArgsVar(&args, "[date...]", "list of dates")
+type ArgsTime struct {
+	// Layout is the layout of the arguments. The default is `time.RFC3339`.
+	Layout string
+	// Values are the parsed arguments.
+	Values []time.Time
+}
+
+// Set implements the ArgsValue interface.
+func (a *ArgsTime) Set(args []string) error {
+	layout := a.Layout
+	if layout == "" {
+		layout = time.RFC3339
 	}
-	*a = (*a)[:0] // Reset length to 0.
+	return setOf(&a.Values, args, "time", func(arg string) (time.Time, error) {
+		return time.Parse(layout, arg)
+	})
+}
+
+// ArgsURL are absolute URL positional arguments. If it is created with cap > 0, it will be used to
+// define the number of required arguments.
+type ArgsURL []*url.URL
+
+// Set implements the ArgsValue interface.
+func (a *ArgsURL) Set(args []string) error {
+	return setOf((*[]*url.URL)(a), args, "url", func(arg string) (*url.URL, error) {
+		v, err := url.Parse(arg)
+		if err == nil && !v.IsAbs() {
+			err = fmt.Errorf("not an absolute url")
+		}
+		return v, err
+	})
+}
+
+// ArgsIP are IP address positional arguments. If it is created with cap > 0, it will be used to
+// define the number of required arguments.
+type ArgsIP []net.IP
+
+// Set implements the ArgsValue interface.
+func (a *ArgsIP) Set(args []string) error {
+	return setOf((*[]net.IP)(a), args, "ip", func(arg string) (net.IP, error) {
+		v := net.ParseIP(arg)
+		if v == nil {
+			return nil, fmt.Errorf("invalid ip")
+		}
+		return v, nil
+	})
+}
+
+// ArgsCIDR are network positional arguments in CIDR notation, such as "192.0.2.0/24". If it is
+// created with cap > 0, it will be used to define the number of required arguments.
+type ArgsCIDR []*net.IPNet
+
+// Set implements the ArgsValue interface.
+func (a *ArgsCIDR) Set(args []string) error {
+	return setOf((*[]*net.IPNet)(a), args, "cidr", func(arg string) (*net.IPNet, error) {
+		_, v, err := net.ParseCIDR(arg)
+		return v, err
+	})
+}
+
+// ArgsKeyValue are positional arguments in the form "key=value". Maps have no capacity, so the
+// number of arguments is not limited. It can be combined with `ArgsBetween` to limit it. When a key
+// is given more than once, the last value is used. For example:
+//
+// 	var args cmd.ArgsKeyValue
+// 	root.ArgsVar(&args, "[key=value...]", "labels")
+type ArgsKeyValue map[string]string
+
+// Set implements the ArgsValue interface.
+func (a *ArgsKeyValue) Set(args []string) error {
+	var pairs [][2]string
+	err := setOf(&pairs, args, "key value", func(arg string) ([2]string, error) {
+		eq := strings.Index(arg, "=")
+		if eq <= 0 {
+			return [2]string{}, fmt.Errorf("expected key=value")
+		}
+		return [2]string{arg[:eq], arg[eq+1:]}, nil
+	})
+	if err != nil {
+		return err
+	}
+	values := make(ArgsKeyValue, len(pairs))
+	for _, pair := range pairs {
+		values[pair[0]] = pair[1]
+	}
+	*a = values
+	return nil
+}
+
+// ArgsOf are positional arguments of any type, which are parsed with the Parse function. If Values
+// is created with cap > 0, it will be used to define the number of required arguments. The other
+// positional arguments types of this package are implemented with it. For example, UUID positional
+// arguments:
+//
+// 	args := cmd.ArgsOf[uuid.UUID]{Parse: uuid.Parse}
+// 	root.ArgsVar(&args, "[id...]", "list of ids")
+//
+// When Predictor is set, it is used for the completion of the positional arguments.
+type ArgsOf[T any] struct {
+	// Values are the parsed arguments.
+	Values []T
+	// Parse parses a single argument. It is required.
+	Parse func(string) (T, error)
+	// Predictor is an optional predictor for the completion of the arguments.
+	Predictor complete.Predictor
+
+	// kind is the name of the type in error messages.
+	kind string
+}
+
+// Set implements the ArgsValue interface.
+func (a *ArgsOf[T]) Set(args []string) error {
+	if a.Parse == nil {
+		return fmt.Errorf("positional arguments of type %T have no Parse function", a.Values)
+	}
+	if cap(a.Values) > 0 && len(args) != cap(a.Values) {
+		return fmt.Errorf("required %d positional args, got %v", cap(a.Values), args)
+	}
+	kind := a.kind
+	if kind != "" {
+		kind += " "
+	}
+	values := make([]T, 0, len(args))
 	for i, arg := range args {
-		v, err := strconv.Atoi(arg)
+		v, err := a.Parse(arg)
 		if err != nil {
-			return fmt.Errorf("invalid int positional argument at position %d with value %v", i, arg)
+			return &ArgError{Index: i, Err: fmt.Errorf("invalid %spositional argument at position %d with value %v: %v", kind, i, arg, err)}
 		}
-		*a = append(*a, v)
+		values = append(values, v)
+	}
+	a.Values = values
+	return nil
+}
+
+// Predict implements the complete.Predictor interface.
+func (a *ArgsOf[T]) Predict(prefix string) []string {
+	if a.Predictor == nil {
+		return nil
+	}
+	return a.Predictor.Predict(prefix)
+}
+
+// hasPredictor reports if the arguments define a predictor.
+func (a *ArgsOf[T]) hasPredictor() bool { return a.Predictor != nil }
+
+// setOf sets positional arguments of a slice type with `ArgsOf`.
+func setOf[T any](values *[]T, args []string, kind string, parse func(string) (T, error)) error {
+	a := ArgsOf[T]{Values: *values, Parse: parse, kind: kind}
+	if err := a.Set(args); err != nil {
+		return err
 	}
+	*values = a.Values
 	return nil
 }
+
+// ArgsAtLeast returns positional arguments that require at least min arguments, and are parsed by
+// the given value. The constraint is shown in the usage line. For example:
+//
+// 	var args cmd.ArgsStr
+// 	root.ArgsVar(cmd.ArgsAtLeast(1, &args), "[file...]", "files to process")
+func ArgsAtLeast(min int, value ArgsValue) ArgsValue {
+	return ArgsBetween(min, -1, value)
+}
+
+// ArgsAtMost returns positional arguments that accept at most max arguments, and are parsed by the
+// given value. The constraint is shown in the usage line.
+func ArgsAtMost(max int, value ArgsValue) ArgsValue {
+	return ArgsBetween(0, max, value)
+}
+
+// ArgsBetween returns positional arguments that require between min and max arguments, and are
+// parsed by the given value. A negative max means that there is no maximum. The constraint is
+// shown in the usage line.
+func ArgsBetween(min, max int, value ArgsValue) ArgsValue {
+	if min < 0 || (max >= 0 && max < min) {
+		panic(fmt.Sprintf("invalid positional arguments range: %d to %d", min, max))
+	}
+	return &argsCount{value: value, min: min, max: max, initial: snapshot(value)}
+}
+
+// argsCount is a positional arguments value with count constraints.
+type argsCount struct {
+	value    ArgsValue
+	min, max int
+	initial  reflect.Value
+}
+
+// Set implements the ArgsValue interface.
+func (a *argsCount) Set(args []string) error {
+	if len(args) < a.min || (a.max >= 0 && len(args) > a.max) {
+		err := fmt.Errorf("expected %s, got %d", a.count(), len(args))
+		if len(args) > a.max && a.max >= 0 {
+			return &ArgError{Index: a.max, Err: err}
+		}
+		return err
+	}
+	return a.value.Set(args)
+}
+
+func (a *argsCount) reset() { resetWrapped(a.value, a.initial) }
+
+func (a *argsCount) unwrap() ArgsValue { return a.value }
+
+// count describes the number of expected arguments.
+func (a *argsCount) count() string {
+	switch {
+	case a.min == a.max:
+		return plural(a.min)
+	case a.max < 0:
+		return "at least " + plural(a.min)
+	case a.min == 0:
+		return "at most " + plural(a.max)
+	default:
+		return fmt.Sprintf("%d to %s", a.min, plural(a.max))
+	}
+}
+
+// ArgsStdin returns positional arguments that are parsed by the given value, and are read from
+// the standard input when the argument "-" is given, or when no arguments are given and the
+// standard input is not a terminal. The standard input is read until its end, one argument per
+// line, and empty lines are ignored. The argument "-" is replaced by the arguments that were read.
+// For example, to accept ids from the command line or from a pipe:
+//
+// 	var ids cmd.ArgsStr
+// 	root.ArgsVar(cmd.ArgsStdin(&ids), "[id...]", "ids, or '-' to read from stdin")
+//
+// It should not be used in a `Shell` whose input is not a terminal: The shell reads the command
+// lines from the standard input, so reading the arguments would consume the following lines.
+func ArgsStdin(value ArgsValue) ArgsValue {
+	return &argsStdin{value: value, in: os.Stdin, initial: snapshot(value)}
+}
+
+// argsStdin is a positional arguments value that reads arguments from the standard input.
+type argsStdin struct {
+	value   ArgsValue
+	in      io.Reader
+	initial reflect.Value
+}
+
+// Set implements the ArgsValue interface.
+func (a *argsStdin) Set(args []string) error {
+	if len(args) == 0 && !isTerminal(a.in) {
+		lines, err := readLines(a.in)
+		if err != nil {
+			return fmt.Errorf("reading stdin: %v", err)
+		}
+		return a.value.Set(lines)
+	}
+	return setExpanded(a.value, args, func(arg string) ([]string, error) {
+		if arg != "-" {
+			return []string{arg}, nil
+		}
+		lines, err := readLines(a.in)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %v", err)
+		}
+		return lines, nil
+	})
+}
+
+func (a *argsStdin) reset() { resetWrapped(a.value, a.initial) }
+
+func (a *argsStdin) unwrap() ArgsValue { return a.value }
+
+// readLines reads the non-empty lines of a reader.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// isTerminal reports if a reader is a terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// setExpanded replaces each argument with the arguments that the expand function returns, and sets
+// the value with the result. Errors of the value that point at an expanded argument, point at the
+// original argument.
+func setExpanded(value ArgsValue, args []string, expand func(arg string) ([]string, error)) error {
+	var (
+		expanded []string
+		// origin holds the index in args of each expanded argument.
+		origin []int
+	)
+	for i, arg := range args {
+		values, err := expand(arg)
+		if err != nil {
+			return &ArgError{Index: i, Err: err}
+		}
+		for range values {
+			origin = append(origin, i)
+		}
+		expanded = append(expanded, values...)
+	}
+
+	err := value.Set(expanded)
+	var argErr *ArgError
+	if errors.As(err, &argErr) && argErr.Index >= 0 && argErr.Index < len(origin) {
+		return &ArgError{Index: origin[argErr.Index], Err: argErr.Err}
+	}
+	return err
+}
+
+// wrapper is a positional arguments value that wraps another value.
+type wrapper interface {
+	unwrap() ArgsValue
+}
+
+// unwrapArgs returns the value that is wrapped by positional arguments wrappers, such as the values
+// that are returned by `ArgsBetween`.
+func unwrapArgs(value ArgsValue) ArgsValue {
+	for {
+		w, ok := value.(wrapper)
+		if !ok {
+			return value
+		}
+		value = w.unwrap()
+	}
+}
+
+// argsCountOf returns the count constraints of a positional arguments value, or nil if there are
+// none.
+func argsCountOf(value ArgsValue) *argsCount {
+	for {
+		if count, ok := value.(*argsCount); ok {
+			return count
+		}
+		w, ok := value.(wrapper)
+		if !ok {
+			return nil
+		}
+		value = w.unwrap()
+	}
+}
+
+// resetWrapped resets a wrapped value to its state at definition time.
+func resetWrapped(value ArgsValue, initial reflect.Value) {
+	if r, ok := value.(resetter); ok {
+		r.reset()
+		return
+	}
+	restore(value, initial)
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
@@ -1,8 +1,18 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,8 +66,10 @@ func TestArgsInt(t *testing.T) {
 
 	t.Run("bad value", func(t *testing.T) {
 		var args ArgsInt
-		err := args.Set([]string{"a"})
-		require.Error(t, err)
+		err := args.Set([]string{"1", "a"})
+		var argErr *ArgError
+		require.True(t, errors.As(err, &argErr))
+		assert.Equal(t, 1, argErr.Index)
 	})
 
 	t.Run("too many args", func(t *testing.T) {
@@ -72,3 +84,220 @@ func TestArgsInt(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestArgsBetween(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   ArgsValue
+		args    []string
+		wantErr string
+	}{
+		{value: ArgsBetween(1, 3, new(ArgsStr)), args: []string{"a"}},
+		{value: ArgsBetween(1, 3, new(ArgsStr)), args: []string{"a", "b", "c"}},
+		{value: ArgsBetween(1, 3, new(ArgsStr)), args: nil, wantErr: "expected 1 to 3 arguments, got 0"},
+		{value: ArgsBetween(1, 3, new(ArgsStr)), args: []string{"a", "b", "c", "d", "e"}, wantErr: "expected 1 to 3 arguments, got 5"},
+		{value: ArgsBetween(2, 2, new(ArgsStr)), args: []string{"a"}, wantErr: "expected 2 arguments, got 1"},
+		{value: ArgsAtLeast(1, new(ArgsStr)), args: []string{"a", "b", "c", "d"}},
+		{value: ArgsAtLeast(1, new(ArgsStr)), args: nil, wantErr: "expected at least 1 argument, got 0"},
+		{value: ArgsAtMost(1, new(ArgsStr)), args: nil},
+		{value: ArgsAtMost(1, new(ArgsStr)), args: []string{"a", "b"}, wantErr: "expected at most 1 argument, got 2"},
+		{value: ArgsAtMost(2, new(ArgsInt)), args: []string{"1", "x"}, wantErr: `invalid int positional argument at position 1 with value x: strconv.Atoi: parsing "x": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		err := tt.value.Set(tt.args)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, "args: %v", tt.args)
+		} else {
+			assert.EqualError(t, err, tt.wantErr, "args: %v", tt.args)
+		}
+	}
+
+	assert.Panics(t, func() { ArgsBetween(2, 1, new(ArgsStr)) })
+	assert.Panics(t, func() { ArgsAtLeast(-1, new(ArgsStr)) })
+}
+
+func TestArgsTypes(t *testing.T) {
+	t.Parallel()
+
+	date := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02", s)
+		require.NoError(t, err)
+		return v
+	}
+	mustURL := func(s string) *url.URL {
+		v, err := url.Parse(s)
+		require.NoError(t, err)
+		return v
+	}
+	mustCIDR := func(s string) *net.IPNet {
+		_, v, err := net.ParseCIDR(s)
+		require.NoError(t, err)
+		return v
+	}
+
+	tests := []struct {
+		name    string
+		value   ArgsValue
+		args    []string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "float", value: new(ArgsFloat), args: []string{"1.5", "2"}, want: &ArgsFloat{1.5, 2}},
+		{name: "float bad", value: new(ArgsFloat), args: []string{"1.5", "x"}, wantErr: `invalid float positional argument at position 1 with value x: strconv.ParseFloat: parsing "x": invalid syntax`},
+		{name: "bool", value: new(ArgsBool), args: []string{"true", "0"}, want: &ArgsBool{true, false}},
+		{name: "bool bad", value: new(ArgsBool), args: []string{"yes"}, wantErr: `invalid bool positional argument at position 0 with value yes: strconv.ParseBool: parsing "yes": invalid syntax`},
+		{name: "duration", value: new(ArgsDuration), args: []string{"1s", "2m"}, want: &ArgsDuration{time.Second, 2 * time.Minute}},
+		{name: "duration bad", value: new(ArgsDuration), args: []string{"1"}, wantErr: `invalid duration positional argument at position 0 with value 1: time: missing unit in duration "1"`},
+		{
+			name:  "time",
+			value: &ArgsTime{Layout: "2006-01-02"},
+			args:  []string{"2020-01-02"},
+			want:  &ArgsTime{Layout: "2006-01-02", Values: []time.Time{date("2020-01-02")}},
+		},
+		{name: "time default layout", value: new(ArgsTime), args: []string{"2020-01-02"}, wantErr: `invalid time positional argument at position 0 with value 2020-01-02: parsing time "2020-01-02" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "T"`},
+		{name: "url", value: new(ArgsURL), args: []string{"https://example.com/a"}, want: &ArgsURL{mustURL("https://example.com/a")}},
+		{name: "url relative", value: new(ArgsURL), args: []string{"/a"}, wantErr: "invalid url positional argument at position 0 with value /a: not an absolute url"},
+		{name: "ip", value: new(ArgsIP), args: []string{"192.0.2.1", "::1"}, want: &ArgsIP{net.ParseIP("192.0.2.1"), net.ParseIP("::1")}},
+		{name: "ip bad", value: new(ArgsIP), args: []string{"192.0.2"}, wantErr: "invalid ip positional argument at position 0 with value 192.0.2: invalid ip"},
+		{name: "cidr", value: new(ArgsCIDR), args: []string{"192.0.2.0/24"}, want: &ArgsCIDR{mustCIDR("192.0.2.0/24")}},
+		{name: "cidr bad", value: new(ArgsCIDR), args: []string{"192.0.2.0"}, wantErr: "invalid cidr positional argument at position 0 with value 192.0.2.0: invalid CIDR address: 192.0.2.0"},
+		{name: "key value", value: new(ArgsKeyValue), args: []string{"a=1", "b=", "a=2=3"}, want: &ArgsKeyValue{"a": "2=3", "b": ""}},
+		{name: "key value bad", value: new(ArgsKeyValue), args: []string{"a=1", "=b"}, wantErr: "invalid key value positional argument at position 1 with value =b: expected key=value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.value.Set(tt.args)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				var argErr *ArgError
+				assert.True(t, errors.As(err, &argErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tt.value)
+		})
+	}
+
+	t.Run("cap", func(t *testing.T) {
+		args := make(ArgsFloat, 0, 2)
+		assert.EqualError(t, args.Set([]string{"1"}), "required 2 positional args, got [1]")
+		assert.NoError(t, args.Set([]string{"1", "2"}))
+
+		times := ArgsTime{Values: make([]time.Time, 0, 1)}
+		assert.EqualError(t, times.Set(nil), "required 1 positional args, got []")
+	})
+}
+
+func TestArgsOf(t *testing.T) {
+	t.Parallel()
+
+	type point struct{ x, y int }
+	parsePoint := func(s string) (point, error) {
+		var p point
+		_, err := fmt.Sscanf(s, "%d,%d", &p.x, &p.y)
+		return p, err
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		args := ArgsOf[point]{Parse: parsePoint}
+		require.NoError(t, args.Set([]string{"1,2", "3,4"}))
+		assert.Equal(t, []point{{1, 2}, {3, 4}}, args.Values)
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		args := ArgsOf[point]{Parse: parsePoint}
+		err := args.Set([]string{"1,2", "x"})
+		assert.EqualError(t, err, "invalid positional argument at position 1 with value x: expected integer")
+		var argErr *ArgError
+		require.True(t, errors.As(err, &argErr))
+		assert.Equal(t, 1, argErr.Index)
+	})
+
+	t.Run("cap", func(t *testing.T) {
+		args := ArgsOf[point]{Values: make([]point, 0, 2), Parse: parsePoint}
+		assert.EqualError(t, args.Set([]string{"1,2"}), "required 2 positional args, got [1,2]")
+	})
+
+	t.Run("no parse", func(t *testing.T) {
+		args := ArgsOf[int]{}
+		assert.EqualError(t, args.Set([]string{"1"}), "positional arguments of type []int have no Parse function")
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		root := New(OptName("cmd"))
+		root.ArgsVar(&ArgsOf[point]{Parse: parsePoint, Predictor: predict.Set{"0,0", "1,1"}}, "[point...]", "")
+		assert.Equal(t, []string{"0,0", "1,1"}, root.completeLine(""))
+
+		root = New(OptName("cmd"))
+		root.ArgsVar(&ArgsOf[point]{Parse: parsePoint}, "[point...]", "", predict.OptValues("2,2"))
+		assert.Equal(t, []string{"2,2"}, root.completeLine(""))
+	})
+
+	t.Run("no predictor", func(t *testing.T) {
+		root := New(OptName("cmd"))
+		root.ArgsVar(&ArgsStr{}, "", "", predict.OptValues("root"))
+		sub := root.SubCommand("sub", "")
+		sub.ArgsVar(&ArgsOf[point]{Parse: parsePoint}, "[point...]", "")
+		assert.Nil(t, (*completer)(root.subCmd("sub")).ArgsGet())
+		assert.Nil(t, (*completer)(root.SubCmd).ArgsGet())
+		complete.Test(t, (*completer)(root.SubCmd), "sub ", []string{"-h"})
+
+		root = New(OptName("cmd"))
+		root.ArgsVar(&ArgsOf[point]{Parse: parsePoint}, "[point...]", "")
+		assert.Nil(t, (*completer)(root.SubCmd).ArgsGet())
+	})
+}
+
+func TestArgsStdin(t *testing.T) {
+	t.Parallel()
+
+	newArgs := func(in string) (*argsStdin, *ArgsInt) {
+		var ints ArgsInt
+		args := ArgsStdin(&ints).(*argsStdin)
+		args.in = strings.NewReader(in)
+		return args, &ints
+	}
+
+	t.Run("dash", func(t *testing.T) {
+		args, ints := newArgs("2\n\n 3 \n")
+		require.NoError(t, args.Set([]string{"1", "-", "4"}))
+		assert.Equal(t, ArgsInt{1, 2, 3, 4}, *ints)
+	})
+
+	t.Run("no args and not a terminal", func(t *testing.T) {
+		args, ints := newArgs("1\n2\n")
+		require.NoError(t, args.Set(nil))
+		assert.Equal(t, ArgsInt{1, 2}, *ints)
+	})
+
+	t.Run("args without dash", func(t *testing.T) {
+		args, ints := newArgs("1\n2\n")
+		require.NoError(t, args.Set([]string{"3"}))
+		assert.Equal(t, ArgsInt{3}, *ints)
+	})
+
+	t.Run("error position", func(t *testing.T) {
+		args, _ := newArgs("2\nx\n")
+		err := args.Set([]string{"1", "-", "4", "y"})
+		var argErr *ArgError
+		require.True(t, errors.As(err, &argErr))
+		assert.Equal(t, 1, argErr.Index)
+	})
+
+	t.Run("count and completion", func(t *testing.T) {
+		var strs argsStrComp
+		value := ArgsStdin(ArgsAtMost(2, &strs))
+		value.(*argsStdin).in = strings.NewReader("a\nb\nc\n")
+		assert.EqualError(t, value.Set([]string{"-"}), "expected at most 2 arguments, got 3")
+
+		var out bytes.Buffer
+		root := New(OptName("cmd"), OptOutput(&out), OptErrorHandling(flag.ContinueOnError))
+		root.ArgsVar(value, "[arg...]", "")
+		assert.Equal(t, []string{"one", "two"}, root.completeLine(""))
+		root.ParseArgs("cmd", "-h")
+		assert.Contains(t, out.String(), "Usage: cmd [arg...] (at most 2 arguments)\n")
+	})
+}
@@ -15,6 +15,8 @@
 //
 // - [x] Automatic usage text.
 //
+// - [x] Interactive shell.
+//
 // Usage
 //
 // Define a root command object using the `New` function.
@@ -29,27 +31,42 @@
 //
 // * Minimalistic and `flag`-like.
 //
-// * Any flag that is defined in the base command will be reflected in all of its sub commands.
+// * Any flag that is defined in the base command will be reflected in all of its sub commands. Flags
+// can be defined in any order, also after sub commands were defined.
 //
 // * When user types the command, it starts from the command and sub commands, only then types the
 // flags and then the positional arguments:
 //
 // 	[command] [sub commands...] [flags...] [positional args...]
 //
-// * When a command defines positional arguments, all its sub commands has these positional
-// arguments and thus can't define their own positional arguments.
+// * Positional arguments are defined per command: Positional arguments of a command apply only when
+// it is the invoked command, and each sub command can define its own positional arguments.
+//
+// * A command can have both sub commands and positional arguments. Sub command names take
+// precedence, and other arguments are parsed as the flags and positional arguments of the command.
+// The `Invoked` method tells whether the command itself, and not one of its sub commands, was
+// invoked.
 //
-// * When flag configuration is wrong, the program will panic.
+// * When the configuration of flags, sub commands or positional arguments is wrong, the program will
+// panic. The definition of a command is checked when it is parsed for the first time, and it
+// should not be changed afterwards.
+//
+// * The command tree definition can be checked with the `Validate` method, for example in a unit
+// test.
 package cmd
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/posener/complete/v2"
 	"github.com/posener/complete/v2/compflag"
@@ -60,6 +77,11 @@ import (
 // Cmd is a command that can have set of flags and sub commands.
 type Cmd struct {
 	*SubCmd
+	// line is the command line of the last parse, used to point at the offending argument in
+	// errors.
+	line []string
+	// mu serializes the operations that change the state of the command tree.
+	mu sync.Mutex
 }
 
 // SubCmd is a sub command that can have a set of flags and sub commands.
@@ -71,6 +93,23 @@ type SubCmd struct {
 	sub map[string]*SubCmd
 	// args are the positional arguments. If nil the command does not accept positional arguments.
 	args *argsData
+	// parent is the parent command. It is nil for the root command.
+	parent *SubCmd
+	// build defines the command when it was lazily defined and was not built yet. In that case the
+	// command only holds its configuration.
+	build func(*SubCmd)
+	// frozen is set when the command was parsed for the first time. At that point the command
+	// flags were synced with its parent flags and its definition was checked.
+	frozen bool
+	// visited is set when the command was reached by the parsing, and is cleared when the command is
+	// reset.
+	visited bool
+	// invoked is set when the command was the invoked command in the last parse.
+	invoked bool
+	// unparsed is a copy of the flag set from before it was parsed for the first time.
+	unparsed flag.FlagSet
+	// problems are the problems in the definition of the command, which are reported by `check`.
+	problems []error
 
 	isRoot bool
 }
@@ -80,6 +119,9 @@ type argsData struct {
 	value          ArgsValue
 	usage, details string
 	predict        predict.Config
+	// initial holds a copy of the value at definition time, for values that are pointers. It is
+	// used to reset the value.
+	initial reflect.Value
 }
 
 // ArgsValue is interface for positional arguments variable. It can be used with the
@@ -117,15 +159,30 @@ func (f ArgsFn) Set(args []string) error { return f(args) }
 // config is configuration for root command.
 type config struct {
 	subConfig
-	name          string
-	errorHandling flag.ErrorHandling
-	output        io.Writer
+	name           string
+	errorHandling  flag.ErrorHandling
+	output         io.Writer
+	errOutput      io.Writer
+	exit           func(code int)
+	exitCodes      []exitCode
+	getenv         func(string) string
+	errJSON        bool
+	errCaret       bool
+	responseFiles  bool
+	responseFormat ResponseFormat
+}
+
+// exitCode maps errors to an exit code.
+type exitCode struct {
+	target interface{}
+	code   int
 }
 
 // subConfig is configuration that used both for root command and sub commands.
 type subConfig struct {
-	synopsis string
-	details  string
+	synopsis  string
+	details   string
+	isDefault bool
 }
 
 // optionRoot is an option that can be applied only on the root command and not on sub commands.
@@ -151,6 +208,22 @@ func (f optionFn) applyRoot(cfg *config) { f(&cfg.subConfig) }
 
 func (f optionFn) apply(cfg *subConfig) { f(cfg) }
 
+// optionSubFn is an option function that can be applied only on sub commands.
+type optionSubFn func(cfg *subConfig)
+
+func (f optionSubFn) apply(cfg *subConfig) { f(cfg) }
+
+// OptDefault marks a sub command as the default sub command of its parent. When the parent is
+// called without a sub command name, with no arguments or only with flags, the arguments are
+// parsed by the default sub command. For example, with a default sub command `status`, `mytool`
+// behaves as `mytool status`, and `mytool -v` behaves as `mytool status -v`. Only one sub command
+// of each command can be the default.
+func OptDefault() optionSubFn {
+	return func(cfg *subConfig) {
+		cfg.isDefault = true
+	}
+}
+
 // OptErrorHandling defines the behavior in case of an error in the `Parse` function.
 func OptErrorHandling(errorHandling flag.ErrorHandling) optionRootFn {
 	return func(cfg *config) {
@@ -158,13 +231,103 @@ func OptErrorHandling(errorHandling flag.ErrorHandling) optionRootFn {
 	}
 }
 
-// OptOutput sets the output for the usage.
+// OptOutput sets the output for the usage. It is also used for errors, unless `OptErrOutput` is
+// set.
 func OptOutput(w io.Writer) optionRootFn {
 	return func(cfg *config) {
 		cfg.output = w
 	}
 }
 
+// OptErrOutput sets the output for errors, which are printed when the error handling is
+// `flag.ExitOnError` and in the interactive shell.
+func OptErrOutput(w io.Writer) optionRootFn {
+	return func(cfg *config) {
+		cfg.errOutput = w
+	}
+}
+
+// OptExit sets the function that is called to exit the program when the error handling is
+// `flag.ExitOnError` and in the `Exit` method. The default is `os.Exit`.
+func OptExit(exit func(code int)) optionRootFn {
+	return func(cfg *config) {
+		cfg.exit = exit
+	}
+}
+
+// OptExitCode sets the exit code for errors that match the given target, as in `errors.As`. For
+// example, to exit with code 127 when the sub command is unknown:
+//
+// 	cmd.OptExitCode(new(*cmd.UnknownCommandError), 127)
+//
+// The option can be given more than once, and the first matching option defines the exit code. It
+// panics if the target is not a non-nil pointer to an interface or to a type that implements error.
+func OptExitCode(target interface{}, code int) optionRootFn {
+	return func(cfg *config) {
+		checkExitCodeTarget(target)
+		cfg.exitCodes = append(cfg.exitCodes, exitCode{target: target, code: code})
+	}
+}
+
+// OptExpandEnv enables expansion of environment variables in the forms $NAME and ${NAME} in
+// `ParseString` and in the interactive shell, using the given function. For example, expand
+// variables of the process environment with `OptExpandEnv(os.Getenv)`.
+func OptExpandEnv(getenv func(string) string) optionRootFn {
+	return func(cfg *config) {
+		cfg.getenv = getenv
+	}
+}
+
+// OptErrJSON prints errors as JSON objects to the error output, instead of free text, such that
+// they can be parsed by other programs. Each error is printed as a single line with the fields of
+// `JSONError`. It applies to errors that are printed when the error handling is `flag.ExitOnError`,
+// in the `Exit` method and in the interactive shell.
+func OptErrJSON() optionRootFn {
+	return func(cfg *config) {
+		cfg.errJSON = true
+	}
+}
+
+// OptErrCaret prints, after an error in the command line, the command line with a marker under the
+// argument that caused the error. For example:
+//
+// 	cmd > cmd sub: bad positional args: arg "three": not in allowed values: one,two
+// 	cmd sub one three
+// 	            ^^^^^
+//
+// It applies to errors that are printed when the error handling is `flag.ExitOnError`, in the
+// `Exit` method and in the interactive shell. It has no effect when `OptErrJSON` is used.
+func OptErrCaret() optionRootFn {
+	return func(cfg *config) {
+		cfg.errCaret = true
+	}
+}
+
+// OptResponseFiles enables response files in `ParseArgs`: An argument of the form `@path` is
+// replaced by the arguments in the file at the given path, in the given format. It enables passing
+// many arguments without exceeding the command line length limit, for example:
+//
+// 	ls *.txt > files.txt
+// 	mytool process @files.txt
+func OptResponseFiles(format ResponseFormat) optionRootFn {
+	return func(cfg *config) {
+		cfg.responseFiles = true
+		cfg.responseFormat = format
+	}
+}
+
+// ResponseFormat is the format of response files.
+type ResponseFormat int
+
+const (
+	// ResponseLines is a file with an argument in each line, such as a list of file names. White
+	// spaces around the arguments and empty lines are ignored.
+	ResponseLines ResponseFormat = iota
+	// ResponseQuoted is a file where arguments are separated by white spaces or new lines, and can
+	// be quoted as in a shell.
+	ResponseQuoted
+)
+
 // OptName sets a predefined name to the root command.
 func OptName(name string) optionRootFn {
 	return func(cfg *config) {
@@ -193,11 +356,15 @@ func New(options ...optionRoot) *Cmd {
 		name:          os.Args[0],
 		errorHandling: flag.ExitOnError,
 		output:        os.Stderr,
+		exit:          os.Exit,
 	}
 	// Update with requested options.
 	for _, option := range options {
 		option.applyRoot(&cfg)
 	}
+	if cfg.errOutput == nil {
+		cfg.errOutput = cfg.output
+	}
 
 	return newCmd(cfg)
 }
@@ -207,11 +374,67 @@ func (c *Cmd) Parse() error {
 	return c.ParseArgs(os.Args...)
 }
 
-// ParseArgs a set of arguments.
+// ParseArgs a set of arguments. The command can be parsed more than once, for example in an
+// interactive program. In that case, the state of the previous parse is reset before parsing, as
+// in the `Reset` method.
 func (c *Cmd) ParseArgs(args ...string) error {
+	return c.handleError(c.parseArgs(args))
+}
+
+func (c *Cmd) parseArgs(args []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.SubCmd.reset()
 	c.complete(args)
+	c.line = args
+	if c.responseFiles {
+		var err error
+		if args, err = c.expandResponseFiles(args); err != nil {
+			return err
+		}
+		c.line = args
+	}
 	_, err := c.parse(args)
-	return c.handleError(err)
+	return err
+}
+
+// ParseString parses a command line from a string. The string should not contain the command
+// name, for example:
+//
+// 	root.ParseString(`sub1 -flag1 'hello world' arg`)
+//
+// The string is split to arguments as in a POSIX shell: Arguments are separated by white spaces,
+// can be quoted with single or double quotes, and a backslash escapes the following character.
+// Environment variables are expanded only when the `OptExpandEnv` option is set. Errors are
+// returned as `*LineError`, which points at the column of the offending argument in the string.
+func (c *Cmd) ParseString(line string) error {
+	return c.handleError(c.parseString(line))
+}
+
+func (c *Cmd) parseString(line string) error {
+	words, err := splitWords(line, c.getenv)
+	if err != nil {
+		return err
+	}
+	args := append([]string{c.name}, texts(words)...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SubCmd.reset()
+	c.line = nil
+	_, err = c.parse(args)
+
+	// Point at the column of the offending argument.
+	var posErr positioned
+	if errors.As(err, &posErr) {
+		column, width := len(line)+1, 1
+		if i := posErr.index(args) - 1; i >= 0 && i < len(words) {
+			column, width = words[i].col, words[i].end-words[i].col
+		}
+		err = &LineError{Line: line, Column: column, Err: err, width: width}
+	}
+	return err
 }
 
 func (c *Cmd) handleError(err error) error {
@@ -220,42 +443,114 @@ func (c *Cmd) handleError(err error) error {
 	}
 	switch c.errorHandling {
 	case flag.ExitOnError:
-		fmt.Fprintf(os.Stderr, err.Error()+"\n")
-		os.Exit(2)
+		c.Exit(err)
 	case flag.PanicOnError:
 		panic(err)
 	}
 	return err
 }
 
+// Exit exits the program with an exit code according to the given error, after printing the error
+// to the error output. It exits with code 0 when the error is nil or when it is a help request. It
+// can be used to exit with the result of the program logic:
+//
+// 	func main() {
+// 		root.Parse()
+// 		root.Exit(run())
+// 	}
+//
+// The exit code is defined by the `OptExitCode` options and by errors that implement the
+// `ExitCoder` interface. Otherwise, errors in the command line usage exit with `ExitUsage`, and other
+// errors exit with `ExitFailure`.
+func (c *Cmd) Exit(err error) {
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		c.printError(err)
+	}
+	c.exit(c.exitCode(err))
+}
+
 // SubCommand creates a new sub command to the given command.
 func (c *SubCmd) SubCommand(name string, synopsis string, options ...option) *SubCmd {
-	if len(name) == 0 {
-		panic("subcommand can't be empty")
+	cfg, ok := c.subConfig(name, synopsis, options)
+	subCmd := c.newChild(cfg)
+	if ok {
+		c.sub[name] = subCmd
 	}
-	if name[0] == '-' {
-		panic("subcommand can't start with a dash")
+	return subCmd
+}
+
+// LazySubCommand defines a new sub command to the given command which is built only when it is
+// needed. The build function is called with the new sub command, and should define its flags,
+// positional arguments and sub commands. It is called only when parsing, usage or completion
+// reach the sub command, which saves the definition cost of unused sub commands in programs
+// with many sub commands. Usage example:
+//
+// 	var root = cmd.New()
+//
+// 	func init() {
+// 		root.LazySubCommand("serve", "run the server", func(sub *cmd.SubCmd) {
+// 			port := sub.Int("port", 8080, "port to listen on")
+// 			...
+// 		})
+// 	}
+func (c *SubCmd) LazySubCommand(name string, synopsis string, build func(*SubCmd), options ...option) {
+	if cfg, ok := c.subConfig(name, synopsis, options); ok {
+		c.sub[name] = &SubCmd{config: cfg, build: build}
 	}
-	if c.sub[name] != nil {
-		panic(fmt.Sprintf("sub command %q already exists", name))
+}
+
+// subConfig returns the configuration of a new sub command. It returns false if the sub command
+// can't be added to the command, in which case the problem is recorded in the command.
+func (c *SubCmd) subConfig(name string, synopsis string, options []option) (config, bool) {
+	ok := false
+	switch {
+	case len(name) == 0:
+		c.addProblem("sub command of %s has an empty name", c.name)
+	case name[0] == '-':
+		c.addProblem("sub command %q of %s starts with a dash", name, c.name)
+	case c.sub[name] != nil:
+		c.addProblem("sub command %q of %s is defined more than once", name, c.name)
+	default:
+		ok = true
 	}
 
 	cfg := c.config
 	cfg.name = c.name + " " + name
 	cfg.synopsis = synopsis
 	cfg.details = ""
+	cfg.isDefault = false
 	// Update with requested options.
 	for _, option := range options {
 		option.apply(&cfg.subConfig)
 	}
+	if def := c.defaultSub(); cfg.isDefault && def != "" {
+		c.addProblem("sub commands %q and %q of %s are both defined as default", def, name, c.name)
+		cfg.isDefault = false
+	}
+	return cfg, ok
+}
 
-	subCmd := newSubCmd(cfg, c.FlagSet)
-	subCmd.args = c.args
-
-	c.sub[name] = subCmd
+// newChild creates a sub command with the given configuration.
+func (c *SubCmd) newChild(cfg config) *SubCmd {
+	subCmd := newSubCmd(cfg)
+	subCmd.parent = c
+	subCmd.inheritFlags()
 	return subCmd
 }
 
+// subCmd returns the sub command with the given name, or nil if it does not exist. A lazily
+// defined sub command is built on the first call.
+func (c *SubCmd) subCmd(name string) *SubCmd {
+	sub := c.sub[name]
+	if sub != nil && sub.build != nil {
+		build := sub.build
+		sub = c.newChild(sub.config)
+		c.sub[name] = sub
+		build(sub)
+	}
+	return sub
+}
+
 // Args returns the positional arguments for the command and enable defining options. Only a sub
 // command that called this method accepts positional arguments. Calling a sub command with
 // positional arguments where they were not defined result in parsing error. The provided options
@@ -280,14 +575,14 @@ func (c *SubCmd) Args(usage, details string, options ...predict.Option) *[]strin
 //
 // The value argument can optionally implement `github.com/posener/complete.Predictor` interface.
 // Then, command completion for the predictor will apply.
+//
+// A command can have both sub commands and positional arguments. In that case, when the first
+// argument is a name of a sub command, the sub command is invoked, and otherwise the arguments are
+// parsed as the flags and positional arguments of the command itself.
 func (c *SubCmd) ArgsVar(value ArgsValue, usage, details string, options ...predict.Option) {
-	// If subcommands were set, positional arguments can't be set anymore.
-	if len(c.sub) > 0 {
-		panic("positional args must be defined before defining sub commands")
-	}
-
 	if c.args != nil {
-		panic("Args() or ArgsVar() called more than once.")
+		c.addProblem("positional arguments of %s are defined more than once", c.name)
+		return
 	}
 	c.args = &argsData{
 		value:   value,
@@ -295,6 +590,7 @@ func (c *SubCmd) ArgsVar(value ArgsValue, usage, details string, options ...pred
 		details: details,
 		predict: predict.Options(options...),
 	}
+	c.args.initial = snapshot(value)
 
 	if c.args.usage == "" {
 		c.args.usage = "[args...]"
@@ -306,67 +602,130 @@ func (c *SubCmd) parse(args []string) ([]string, error) {
 		panic("must be at least the command in arguments")
 	}
 
-	c.checkFlagsTree(make(map[string]bool))
+	c.freeze()
+	c.visited = true
 
 	// First argument is the command name.
 	args = args[1:]
 
 	// If command has sub commands, find it and parse the sub command.
 	if len(c.sub) > 0 {
-		if len(args) == 0 {
-			c.Usage()
-			return nil, fmt.Errorf("must provide sub command")
+		// Route missing or flag-only input to the default sub command.
+		if def := c.defaultSub(); def != "" && (len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelp(args[0]))) {
+			args = append([]string{def}, args...)
 		}
-		name := args[0]
-		if c.sub[name] == nil {
-			// Check for help flag, which can be applied on any level of sub command.
-			if name == "-h" || name == "-help" || name == "--help" {
+		var sub *SubCmd
+		if len(args) > 0 {
+			sub = c.subCmd(args[0])
+		}
+		switch {
+		case sub != nil:
+			args, err := sub.parse(args)
+			if err != nil {
+				return nil, fmt.Errorf("%s > %w", c.name, err)
+			}
+			// The command is marked as parsed, but its positional arguments only apply when it is
+			// the invoked command.
+			c.FlagSet.Parse(nil)
+			return args, nil
+		case c.args != nil:
+			// Not a sub command, fall back to the flags and positional arguments of the command.
+		case len(args) == 0:
+			if !c.errJSON {
 				c.Usage()
-				return nil, flag.ErrHelp
 			}
-			return nil, fmt.Errorf("invalid command: %s", name)
-		}
-		var err error
-		args, err = c.sub[name].parse(args)
-		if err != nil {
-			return nil, fmt.Errorf("%s > %v", c.name, err)
+			return nil, &MissingSubCommandError{Path: c.name, Suggestions: c.subNames()}
+		case isHelp(args[0]):
+			// Check for help flag, which can be applied on any level of sub command.
+			c.Usage()
+			return nil, flag.ErrHelp
+		default:
+			return nil, &UnknownCommandError{
+				Path:        c.name,
+				Name:        args[0],
+				Suggestions: similar(args[0], c.subNames()),
+				position:    position{fromEnd: len(args)},
+			}
 		}
 	}
 
 	// Check for command flags, and update the remaining arguments.
 	err := c.FlagSet.Parse(args)
 	if err != nil {
-		return nil, fmt.Errorf("%s: bad flags: %w", c.name, err)
+		if c.errJSON && errors.Is(err, flag.ErrHelp) {
+			// The usage is printed only when it is requested.
+			c.Usage()
+		}
+		return nil, c.flagError(args, err)
 	}
 	args = c.FlagSet.Args()
 
 	// Collect positional arguments if required.
 	args, err = c.setArgs(args)
 	if err != nil {
-		return nil, fmt.Errorf("%s: bad positional args: %v", c.name, err)
+		return nil, err
 	}
 
+	c.invoked = true
 	return args, nil
 }
 
+// Invoked returns true if the command was the invoked command in the last parse, which is the last
+// command in the command line. Unlike `Parsed`, which is true for all the commands in the command
+// line, it is false for the parent commands of the invoked command. For example, a command that
+// has both sub commands and positional arguments can use it to tell whether its own action should
+// run:
+//
+// 	if run.Invoked() {
+// 		runScript(*script)
+// 	}
+func (c *SubCmd) Invoked() bool {
+	return c.invoked
+}
+
 func (c *SubCmd) setArgs(args []string) ([]string, error) {
 	if c.args == nil {
 		if len(args) > 0 {
-			return nil, fmt.Errorf("positional args not expected, got %v", args)
+			return nil, c.argsError(args, 0, fmt.Errorf("positional args not expected, got %v", args))
 		}
 		return args, nil
 	}
-	for _, arg := range args {
-		err := c.args.predict.Check(arg)
+	for i, arg := range args {
+		config := c.args.predict
+		if named, ok := c.args.value.(*namedArgs); ok {
+			config = named.config(i)
+		}
+		err := config.Check(arg)
 		if err != nil {
-			return nil, fmt.Errorf("arg %q: %v", arg, err)
+			checkErr := &CheckError{Path: c.name, Arg: arg, Err: err, Suggestions: predictedValues(config, nil)}
+			return nil, c.argsError(args, i, checkErr)
+		}
+	}
+	if err := c.args.value.Set(args); err != nil {
+		var argErr *ArgError
+		if errors.As(err, &argErr) && argErr.Index >= 0 && argErr.Index < len(args) {
+			return nil, c.argsError(args, argErr.Index, err)
 		}
+		e := c.argsError(args, 0, err)
+		e.Arg = ""
+		return nil, e
 	}
-	return nil, c.args.value.Set(args)
+	return nil, nil
+}
+
+// argsError returns an error for the positional arguments, which was caused by the argument in
+// the given index.
+func (c *SubCmd) argsError(args []string, i int, err error) *ArgsError {
+	e := &ArgsError{Path: c.name, Err: err, position: position{fromEnd: len(args) - i}}
+	if i < len(args) {
+		e.Arg = args[i]
+	}
+	return e
 }
 
 // Usage prints the sub command usage to the defined output.
 func (c *SubCmd) Usage() {
+	c.syncFlags()
 	w := c.output
 	detailsW := detailsWriter(w)
 	subs := c.subNames()
@@ -374,19 +733,27 @@ func (c *SubCmd) Usage() {
 	// Constract usage string.
 
 	usage := "Usage: " + c.name
-	if len(subs) == 0 {
+	if len(subs) > 0 {
+		subcommands := "[" + strings.Join(subs, "|") + "]"
+		if len(subcommands) > 30 {
+			subcommands = "[subcommands...]"
+		}
+		usage += " " + subcommands
+	}
+	// A command with sub commands and positional arguments has a second form of usage.
+	if len(subs) > 0 && c.args != nil {
+		usage += "\n       " + c.name
+	}
+	if len(subs) == 0 || c.args != nil {
 		if c.hasFlags() {
 			usage += " [flags]"
 		}
 		if c.args != nil {
 			usage += " " + c.args.usage
+			if count := argsCountOf(c.args.value); count != nil {
+				usage += " (" + count.count() + ")"
+			}
 		}
-	} else {
-		subcommands := "[" + strings.Join(subs, "|") + "]"
-		if len(subcommands) > 30 {
-			subcommands = "[subcommands...]"
-		}
-		usage += " " + subcommands
 	}
 
 	// Add synopsis and details.
@@ -415,17 +782,26 @@ func (c *SubCmd) Usage() {
 		}
 
 		for _, name := range subs {
-			fmt.Fprintf(w, "  %-*s\t%s\n", subLength, name, c.sub[name].synopsis)
+			synopsis := c.sub[name].synopsis
+			if c.sub[name].isDefault {
+				synopsis = strings.TrimSpace(synopsis + " (default)")
+			}
+			fmt.Fprintf(w, "  %-*s\t%s\n", subLength, name, synopsis)
 		}
 		fmt.Fprintf(w, "\n")
-		// Print completion options only to the root command.
-		if c.isRoot && detectCompletionSupport() {
-			fmt.Fprintln(w, completionUsage(c.name))
+		if c.args != nil {
+			fmt.Fprintf(w, "Sub command names take precedence over positional arguments.\n\n")
 		}
-	} else {
+	}
+	if len(c.sub) == 0 || c.args != nil {
 		if c.hasFlags() {
 			fmt.Fprintf(w, "Flags:\n\n")
-			c.FlagSet.PrintDefaults()
+			// The flag set output may be discarded, print the flags to the command output.
+			fs := (*flag.FlagSet)(c.FlagSet)
+			out := fs.Output()
+			fs.SetOutput(w)
+			fs.PrintDefaults()
+			fs.SetOutput(out)
 			fmt.Fprintf(w, "\n")
 		}
 
@@ -435,6 +811,10 @@ func (c *SubCmd) Usage() {
 			fmt.Fprintf(w, "\n\n")
 		}
 	}
+	// Print completion options only to the root command.
+	if len(c.sub) > 0 && c.isRoot && detectCompletionSupport() {
+		fmt.Fprintln(w, completionUsage(c.name))
+	}
 }
 
 // subNames return all sub commands ordered alphabetically.
@@ -447,30 +827,196 @@ func (c *SubCmd) subNames() []string {
 	return names
 }
 
+// defaultSub returns the name of the default sub command, or an empty string if there is none.
+func (c *SubCmd) defaultSub() string {
+	for name, sub := range c.sub {
+		if sub.isDefault {
+			return name
+		}
+	}
+	return ""
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
 func (c *SubCmd) hasFlags() bool {
 	hasFlags := false
 	c.VisitAll(func(*flag.Flag) { hasFlags = true })
 	return hasFlags
 }
 
-// checkFlagsTree checks that each sub command contains at least all its parent's flags. This is
-// needed because the flag parsing is done only in leaf sub commands. If the user have defined the
-// flag commands before creating any sub command, the checked condition will hold.
+// Validate checks the definition of the command tree and returns all the problems that were found.
+// The returned error is of type `ValidationError`. The problems include invalid flags, and sub
+// commands and positional arguments that were defined more than once or in a wrong way. These
+// problems cause the `Parse` method to panic, and this method enables asserting the tree validity
+// in a unit test. It builds all the sub commands that were defined with `LazySubCommand`.
 //
-// This function panics when invalid state has been found.
-func (c *SubCmd) checkFlagsTree(parent map[string]bool) {
-	current := make(map[string]bool)
-	c.FlagSet.VisitAll(func(f *flag.Flag) {
-		current[f.Name] = true
-	})
-	for p := range parent {
-		if !current[p] {
-			panic(fmt.Sprintf("flag %s was defined after sub commands %s", p, c.name))
+// 	func TestCmd(t *testing.T) {
+// 		if err := root.Validate(); err != nil {
+// 			t.Fatal(err)
+// 		}
+// 	}
+func (c *Cmd) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.validate()
+}
+
+// ValidationError lists the problems that were found in a command tree definition.
+type ValidationError []error
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// validate checks the command and all its sub commands. It returns a `ValidationError` if problems
+// were found, or nil otherwise.
+func (c *SubCmd) validate() error {
+	c.syncFlags()
+	errs := c.collectErrors()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (c *SubCmd) collectErrors() ValidationError {
+	errs := ValidationError(c.check())
+	for _, name := range c.subNames() {
+		sub := c.subCmd(name)
+		sub.inheritFlags()
+		errs = append(errs, sub.collectErrors()...)
+	}
+	return errs
+}
+
+// check returns the definition problems of the command. It assumes that the command flags were
+// synced with its parent flags.
+//
+// Each sub command should have all its parent's flags with the same values, since the flag
+// parsing is done only in leaf sub commands. A sub command that defines its own flag with the
+// name of a flag that was later defined in its parent breaks this condition.
+func (c *SubCmd) check() []error {
+	errs := append([]error(nil), c.problems...)
+	parent := c.parent
+	if parent != nil {
+		parent.VisitAll(func(f *flag.Flag) {
+			if c.Lookup(f.Name).Value != f.Value {
+				errs = append(errs, fmt.Errorf("flag %s of %s is redefined in sub command %s", f.Name, parent.name, c.name))
+			}
+		})
+	}
+	c.VisitAll(func(f *flag.Flag) {
+		checker, ok := f.Value.(interface{ Check(string) error })
+		if !ok || f.DefValue == "" {
+			return
+		}
+		// Inherited flags are checked in the command that defined them.
+		if parent != nil && parent.Lookup(f.Name) != nil {
+			return
 		}
+		if err := checker.Check(f.DefValue); err != nil {
+			errs = append(errs, fmt.Errorf("flag %s of %s has invalid default value %q: %v", f.Name, c.name, f.DefValue, err))
+		}
+	})
+	return errs
+}
+
+// Reset clears the state of a previous parse: It sets the flags to their default values, sets the
+// positional arguments variables to their value at definition time, and clears the `Parsed` state
+// of the commands. Positional arguments variables that are not pointers, such as `ArgsFn`, are not
+// reset.
+//
+// Reset is called by `ParseArgs`, such that a command tree can be used for many invocations, for
+// example in an interactive program. It only resets the flags that were given and the commands
+// that were visited, so its cost does not depend on the size of the command tree.
+//
+// Parsing and resetting are serialized, such that concurrent invocations don't corrupt the state
+// of the command tree. The flags and positional arguments are bound to variables that the program
+// defined, and each parse overrides them, so programs that serve concurrent invocations and read
+// the parsed values should define a command tree for each invocation.
+func (c *Cmd) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SubCmd.reset()
+}
+
+// addProblem records a problem in the definition of the command.
+func (c *SubCmd) addProblem(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Errorf(format, args...))
+}
+
+// reset resets the command and its sub commands that were visited by the parsing.
+func (c *SubCmd) reset() {
+	if !c.visited {
+		return
+	}
+	for _, sub := range c.sub {
+		sub.reset()
+	}
+
+	// Reset only the flags that were set in this command. Inherited flags share their value with
+	// the parent, so each value is reset by the command that it was set in. Then restore the flag
+	// set to its state before the first parse, which clears the parsed state without defining the
+	// flags again.
+	c.Visit(resetFlag)
+	*(*flag.FlagSet)(c.FlagSet) = c.unparsed
+
+	c.resetArgs()
+	c.visited = false
+	c.invoked = false
+}
+
+// resetArgs sets the positional arguments variable to its value at definition time.
+func (c *SubCmd) resetArgs() {
+	if c.args == nil {
+		return
 	}
-	for _, subcmd := range c.sub {
-		subcmd.checkFlagsTree(current)
+	if r, ok := c.args.value.(resetter); ok {
+		r.reset()
+		return
 	}
+	restore(c.args.value, c.args.initial)
+}
+
+// snapshot returns a copy of the value that a pointer points to. It returns an invalid value if
+// the given value is not a pointer.
+func snapshot(ptr interface{}) reflect.Value {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return reflect.Value{}
+	}
+	initial := reflect.New(v.Elem().Type()).Elem()
+	initial.Set(v.Elem())
+	return initial
+}
+
+// restore sets the value that a pointer points to, to a value that was returned by snapshot.
+func restore(ptr interface{}, initial reflect.Value) {
+	if initial.IsValid() {
+		reflect.ValueOf(ptr).Elem().Set(initial)
+	}
+}
+
+// resetter is a value that can be reset to its state at definition time.
+type resetter interface {
+	reset()
+}
+
+// resetFlag sets the flag value to its default.
+func resetFlag(f *flag.Flag) {
+	if r, ok := f.Value.(resetter); ok {
+		r.reset()
+		return
+	}
+	// Values that fail the predict check are set regardless of the returned error.
+	f.Value.Set(f.DefValue)
 }
 
 // complete performs bash completion when required.
@@ -478,19 +1024,65 @@ func (c *Cmd) complete(args []string) {
 	complete.Complete(c.name, (*completer)(c.SubCmd))
 }
 
+// freeze syncs the command flags with its parent flags and checks the command definition. It is
+// done only once, when the command is parsed for the first time, and assumes that the parent
+// command was already frozen. This way, each command is checked once, and repeated parsing does not
+// walk the command tree.
+//
+// This function panics when invalid definition has been found.
+func (c *SubCmd) freeze() {
+	if c.frozen {
+		return
+	}
+	if c.parent != nil {
+		c.inheritFlags()
+	}
+	if errs := c.check(); len(errs) > 0 {
+		panic(ValidationError(errs))
+	}
+	// The copy shares the flag definitions, and is used to clear the parsed state on reset.
+	c.unparsed = *(*flag.FlagSet)(c.FlagSet)
+	c.frozen = true
+}
+
+// syncFlags updates the command and its ancestors with the flags that were defined in their
+// ancestors. Frozen commands are not updated.
+func (c *SubCmd) syncFlags() {
+	if c.parent == nil || c.frozen {
+		return
+	}
+	c.parent.syncFlags()
+	c.inheritFlags()
+}
+
+// inheritFlags adds to the command the flags of its parent that it does not have yet. The flags
+// share the same value with the parent, such that setting a flag in the sub command will set it
+// in all of its ancestors.
+func (c *SubCmd) inheritFlags() {
+	c.parent.VisitAll(func(f *flag.Flag) {
+		if c.Lookup(f.Name) == nil {
+			(*flag.FlagSet)(c.FlagSet).Var(f.Value, f.Name, f.Usage)
+		}
+	})
+}
+
 func newCmd(cfg config) *Cmd {
-	c := &Cmd{SubCmd: newSubCmd(cfg, nil)}
+	c := &Cmd{SubCmd: newSubCmd(cfg)}
 	c.isRoot = true
 	return c
 }
 
-func newSubCmd(cfg config, parentFs *compflag.FlagSet) *SubCmd {
+func newSubCmd(cfg config) *SubCmd {
 	cmd := &SubCmd{
 		config:  cfg,
-		FlagSet: copyFlagSet(cfg, parentFs),
+		FlagSet: newFlagSet(cfg),
 		sub:     make(map[string]*SubCmd),
 	}
 	cmd.FlagSet.Usage = cmd.Usage
+	if cfg.errJSON {
+		// Errors are printed only as JSON, hence the flag package should not print errors or usage.
+		cmd.FlagSet.Usage = func() {}
+	}
 	return cmd
 }
 
@@ -498,13 +1090,13 @@ func detailsWriter(w io.Writer) io.Writer {
 	return &formatter.Formatter{Writer: w, Width: 80, Indent: []byte("  ")}
 }
 
-func copyFlagSet(cfg config, f *compflag.FlagSet) *compflag.FlagSet {
-	cp := flag.NewFlagSet(cfg.name, flag.ContinueOnError)
-	cp.SetOutput(cfg.output)
-	if f != nil {
-		f.VisitAll(func(fl *flag.Flag) { cp.Var(fl.Value, fl.Name, fl.Usage) })
+func newFlagSet(cfg config) *compflag.FlagSet {
+	fs := flag.NewFlagSet(cfg.name, flag.ContinueOnError)
+	fs.SetOutput(cfg.output)
+	if cfg.errJSON {
+		fs.SetOutput(ioutil.Discard)
 	}
-	return (*compflag.FlagSet)(cp)
+	return (*compflag.FlagSet)(fs)
 }
 
 func detectCompletionSupport() bool {
@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/posener/complete/v2"
 	"github.com/posener/complete/v2/predict"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -381,16 +385,23 @@ func TestCmd_failures(t *testing.T) {
 	t.Parallel()
 
 	t.Run("subcommand valid names", func(t *testing.T) {
-		root := New(OptOutput(ioutil.Discard))
-		assert.Panics(t, func() { root.SubCommand("", "") })
-		assert.Panics(t, func() { root.SubCommand("-name", "") })
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard))
+		root.SubCommand("", "")
+		root.SubCommand("-name", "")
+
+		assert.EqualError(t, root.Validate(), "sub command of cmd has an empty name\n"+
+			"sub command \"-name\" of cmd starts with a dash")
+		assert.Panics(t, func() { root.ParseArgs("cmd") })
 	})
 
 	t.Run("command can't have two sub commands with the same name", func(t *testing.T) {
-		root := New(OptOutput(ioutil.Discard))
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard))
+		sub := root.SubCommand("sub", "")
 		root.SubCommand("sub", "")
 
-		assert.Panics(t, func() { root.SubCommand("sub", "") })
+		assert.Same(t, sub, root.subCmd("sub"))
+		assert.EqualError(t, root.Validate(), "sub command \"sub\" of cmd is defined more than once")
+		assert.Panics(t, func() { root.ParseArgs("cmd", "sub") })
 	})
 
 	t.Run("parse must get at least one argument", func(t *testing.T) {
@@ -399,19 +410,31 @@ func TestCmd_failures(t *testing.T) {
 		assert.Panics(t, func() { root.ParseArgs() })
 	})
 
-	t.Run("defining flag after subcommand is not allowed", func(t *testing.T) {
+	t.Run("defining flag after subcommand is allowed", func(t *testing.T) {
 		root := New(OptOutput(ioutil.Discard))
-		root.SubCommand("sub", "")
+		sub := root.SubCommand("sub", "")
+		subsub := sub.SubCommand("sub", "")
+		flag := root.String("flag", "", "")
+
+		assert.NoError(t, root.ParseArgs("cmd", "sub", "sub", "-flag", "value"))
+		assert.Equal(t, "value", *flag)
+		assert.Equal(t, "value", subsub.Lookup("flag").Value.String())
+	})
+
+	t.Run("sub command flag that is later defined in its parent should panic", func(t *testing.T) {
+		root := New(OptOutput(ioutil.Discard))
+		sub := root.SubCommand("sub", "")
+		sub.String("flag", "", "")
 		root.String("flag", "", "")
 
-		assert.Panics(t, func() { root.ParseArgs("cmd") })
+		assert.Panics(t, func() { root.ParseArgs("cmd", "sub") })
 	})
 
-	t.Run("defining args after subcommand is not allowed", func(t *testing.T) {
+	t.Run("defining args after subcommand is allowed", func(t *testing.T) {
 		root := New(OptOutput(ioutil.Discard))
 		root.SubCommand("sub", "")
 
-		assert.Panics(t, func() { root.Args("flag", "") })
+		assert.NotPanics(t, func() { root.Args("flag", "") })
 	})
 
 	t.Run("both command and sub command have the same flag name should panic", func(t *testing.T) {
@@ -422,30 +445,24 @@ func TestCmd_failures(t *testing.T) {
 		assert.Panics(t, func() { cmd.String("flag", "", "") })
 	})
 
-	t.Run("both command and sub command have positional arguments should panic", func(t *testing.T) {
-		root := New(OptOutput(ioutil.Discard))
-		root.Args("", "")
-		cmd := root.SubCommand("sub", "")
-
-		assert.Panics(t, func() { cmd.Args("", "") })
-	})
-
-	t.Run("both command and sub sub command have positional arguments should panic", func(t *testing.T) {
-		root := New(OptOutput(ioutil.Discard))
-		root.Args("", "")
+	t.Run("command and sub commands may have different positional arguments", func(t *testing.T) {
+		root := New(OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		rootArgs := root.Args("", "")
 		sub := root.SubCommand("sub", "")
+		subArgs := sub.Args("", "")
 		subsub := sub.SubCommand("sub", "")
 
-		assert.Panics(t, func() { subsub.Args("", "") })
-	})
+		assert.NoError(t, root.ParseArgs("cmd", "a"))
+		assert.Equal(t, []string{"a"}, *rootArgs)
+		assert.Empty(t, *subArgs)
 
-	t.Run("both sub command and sub sub command have positional arguments should panic", func(t *testing.T) {
-		root := New(OptOutput(ioutil.Discard))
-		sub := root.SubCommand("sub", "")
-		sub.Args("", "")
-		subsub := sub.SubCommand("sub", "")
+		assert.NoError(t, root.ParseArgs("cmd", "sub", "b"))
+		assert.Empty(t, *rootArgs)
+		assert.Equal(t, []string{"b"}, *subArgs)
 
-		assert.Panics(t, func() { subsub.Args("", "") })
+		assert.NoError(t, root.ParseArgs("cmd", "sub", "sub"))
+		assert.True(t, subsub.Parsed())
+		assert.Error(t, root.ParseArgs("cmd", "sub", "sub", "c"), "positional args of parents don't apply")
 	})
 
 	t.Run("two different sub command may have positional arguments", func(t *testing.T) {
@@ -459,10 +476,12 @@ func TestCmd_failures(t *testing.T) {
 	})
 
 	t.Run("calling positional more than once is not allowed", func(t *testing.T) {
-		root := New(OptOutput(ioutil.Discard))
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard))
+		root.Args("", "")
 		root.Args("", "")
 
-		assert.Panics(t, func() { root.Args("", "") })
+		assert.EqualError(t, root.Validate(), "positional arguments of cmd are defined more than once")
+		assert.Panics(t, func() { root.ParseArgs("cmd") })
 	})
 
 	t.Run("calling without sub commands fails with usage", func(t *testing.T) {
@@ -474,3 +493,440 @@ func TestCmd_failures(t *testing.T) {
 		}
 	})
 }
+
+func TestCmd_Validate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid tree", func(t *testing.T) {
+		assert.NoError(t, newTestCmd().Validate())
+	})
+
+	t.Run("all problems are reported", func(t *testing.T) {
+		root := New(OptOutput(ioutil.Discard))
+		sub := root.SubCommand("sub", "")
+		sub.String("redefined", "", "")
+		sub.String("sub-default", "baz", "", predict.OptValues("foo", "bar"), predict.OptCheck())
+		root.String("redefined", "", "")
+		root.String("default", "baz", "", predict.OptValues("foo", "bar"), predict.OptCheck())
+
+		err := root.Validate()
+		var verr ValidationError
+		if assert.True(t, errors.As(err, &verr)) {
+			assert.Len(t, verr, 3)
+		}
+		assert.Panics(t, func() { root.ParseArgs("cmd", "sub") })
+	})
+}
+
+func TestCmd_LazySubCommand(t *testing.T) {
+	t.Parallel()
+
+	var (
+		built int
+		sub   *SubCmd
+		flag1 *string
+	)
+
+	newRoot := func() *Cmd {
+		built = 0
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		root.SubCommand("eager", "")
+		root.LazySubCommand("lazy", "lazy sub command", func(s *SubCmd) {
+			built++
+			sub = s
+			flag1 = s.String("flag1", "", "")
+			s.SubCommand("sub", "")
+		})
+		root.Bool("flag0", false, "")
+		return root
+	}
+
+	t.Run("not built when not used", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "eager"))
+		assert.Equal(t, 0, built)
+	})
+
+	t.Run("built when parsed", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "lazy", "sub", "-flag0", "-flag1", "value"))
+		assert.Equal(t, "value", *flag1)
+		assert.NoError(t, root.ParseArgs("cmd", "lazy", "sub"))
+		assert.Equal(t, 1, built)
+		assert.True(t, sub.Parsed())
+	})
+
+	t.Run("built when validated", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.Validate())
+		assert.Equal(t, 1, built)
+	})
+
+	t.Run("built when completed", func(t *testing.T) {
+		root := newRoot()
+		complete.Test(t, (*completer)(root.SubCmd), "lazy ", []string{"sub", "-h"})
+		assert.Equal(t, 1, built)
+	})
+}
+
+// newBenchCmd returns a command tree where each command has a flag and the given number of sub
+// commands, up to the given depth.
+func newBenchCmd(width, depth int) *Cmd {
+	root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+	var define func(c *SubCmd, depth int)
+	define = func(c *SubCmd, depth int) {
+		c.String("flag"+strconv.Itoa(depth), "", "", predict.OptValues("foo", "bar"), predict.OptCheck())
+		if depth == 0 {
+			return
+		}
+		for i := 0; i < width; i++ {
+			define(c.SubCommand("sub"+strconv.Itoa(i), ""), depth-1)
+		}
+	}
+	define(root.SubCmd, depth)
+	return root
+}
+
+// benchArgs returns the command line of the last leaf sub command of a bench command.
+func benchArgs(width, depth int) []string {
+	args := []string{"cmd"}
+	for i := 0; i < depth; i++ {
+		args = append(args, "sub"+strconv.Itoa(width-1))
+	}
+	return append(args, "-flag0", "foo", "-flag"+strconv.Itoa(depth), "bar")
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchmarks := []struct {
+		name         string
+		width, depth int
+	}{
+		{name: "wide", width: 10, depth: 3},
+		{name: "deep", width: 1, depth: 1000},
+	}
+
+	for _, bb := range benchmarks {
+		root := newBenchCmd(bb.width, bb.depth)
+		args := benchArgs(bb.width, bb.depth)
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := root.ParseArgs(args...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCmd_defaultSubCommand(t *testing.T) {
+	t.Parallel()
+
+	var (
+		out    bytes.Buffer
+		status *SubCmd
+		other  *SubCmd
+		v      *bool
+	)
+
+	newRoot := func() *Cmd {
+		out.Reset()
+		root := New(OptName("cmd"), OptOutput(&out), OptErrorHandling(flag.ContinueOnError))
+		status = root.SubCommand("status", "show status", OptDefault())
+		v = status.Bool("v", false, "")
+		other = root.SubCommand("other", "")
+		return root
+	}
+
+	t.Run("no args", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd"))
+		assert.True(t, status.Parsed())
+		assert.False(t, other.Parsed())
+	})
+
+	t.Run("flags only", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "-v"))
+		assert.True(t, status.Parsed())
+		assert.True(t, *v)
+	})
+
+	t.Run("explicit sub command", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "other"))
+		assert.False(t, status.Parsed())
+		assert.True(t, other.Parsed())
+	})
+
+	t.Run("help", func(t *testing.T) {
+		root := newRoot()
+		assert.True(t, errors.Is(root.ParseArgs("cmd", "-h"), flag.ErrHelp))
+		assert.False(t, status.Parsed())
+		assert.Contains(t, out.String(), "show status (default)")
+	})
+
+	t.Run("unknown sub command", func(t *testing.T) {
+		root := newRoot()
+		assert.Error(t, root.ParseArgs("cmd", "nope"))
+	})
+
+	t.Run("two defaults", func(t *testing.T) {
+		root := newRoot()
+		root.SubCommand("another", "", OptDefault())
+		assert.EqualError(t, root.Validate(), `sub commands "status" and "another" of cmd are both defined as default`)
+		assert.Panics(t, func() { root.ParseArgs("cmd") })
+	})
+
+	t.Run("not inherited", func(t *testing.T) {
+		root := newRoot()
+		sub := status.SubCommand("sub", "")
+		assert.False(t, sub.isDefault)
+		status.SubCommand("sub2", "", OptDefault())
+		assert.NoError(t, root.ParseArgs("cmd"))
+		assert.False(t, sub.Parsed())
+	})
+}
+
+func TestCmd_subCommandsAndArgs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		out    bytes.Buffer
+		run    *SubCmd
+		list   *SubCmd
+		flag1  *bool
+		script *[]string
+	)
+
+	newRoot := func() *Cmd {
+		out.Reset()
+		root := New(OptName("cmd"), OptOutput(&out), OptErrorHandling(flag.ContinueOnError))
+		run = root.SubCommand("run", "")
+		list = run.SubCommand("list", "list scripts")
+		script = run.Args("[script]", "script to run", predict.OptValues("a.sh", "b.sh"))
+		flag1 = run.Bool("flag1", false, "")
+		return root
+	}
+
+	t.Run("sub command", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "run", "list"))
+		assert.True(t, list.Parsed())
+		assert.True(t, list.Invoked())
+		assert.False(t, run.Invoked())
+		assert.Empty(t, *script)
+	})
+
+	t.Run("positional args", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "run", "-flag1", "a.sh"))
+		assert.False(t, list.Parsed())
+		assert.True(t, run.Parsed())
+		assert.True(t, run.Invoked())
+		assert.False(t, list.Invoked())
+		assert.True(t, *flag1)
+		assert.Equal(t, []string{"a.sh"}, *script)
+	})
+
+	t.Run("sub command of a command with required args", func(t *testing.T) {
+		for name, define := range map[string]func(*SubCmd){
+			"exact":    func(c *SubCmd) { args := make(ArgsStr, 0, 1); c.ArgsVar(&args, "[script]", "") },
+			"at least": func(c *SubCmd) { c.ArgsVar(ArgsAtLeast(1, &ArgsStr{}), "[script...]", "") },
+			"named":    func(c *SubCmd) { c.Arg("script", new(string), "") },
+		} {
+			t.Run(name, func(t *testing.T) {
+				root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+				run := root.SubCommand("run", "")
+				list := run.SubCommand("list", "")
+				define(run)
+
+				require.NoError(t, root.ParseArgs("cmd", "run", "list"))
+				assert.True(t, list.Invoked())
+				assert.False(t, run.Invoked())
+				require.NoError(t, root.ParseArgs("cmd", "run", "a.sh"))
+				assert.True(t, run.Invoked())
+				assert.Error(t, root.ParseArgs("cmd", "run"))
+			})
+		}
+	})
+
+	t.Run("no args", func(t *testing.T) {
+		root := newRoot()
+		assert.NoError(t, root.ParseArgs("cmd", "run"))
+		assert.True(t, run.Parsed())
+		assert.False(t, list.Parsed())
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		root := newRoot()
+		assert.True(t, errors.Is(root.ParseArgs("cmd", "run", "-h"), flag.ErrHelp))
+		assert.Contains(t, out.String(), "Usage: cmd run [list]\n       cmd run [flags] [script]\n")
+		assert.Contains(t, out.String(), "Sub command names take precedence over positional arguments.")
+		assert.Contains(t, out.String(), "-flag1")
+		assert.Contains(t, out.String(), "script to run")
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		root := newRoot()
+		assert.Equal(t, []string{"a.sh", "b.sh", "list"}, root.completeLine("run "))
+		assert.Equal(t, []string{"-flag1"}, root.completeLine("run -f"))
+	})
+}
+
+func TestCmd_argsCount(t *testing.T) {
+	t.Parallel()
+
+	var (
+		out  bytes.Buffer
+		args ArgsStr
+	)
+	root := New(OptName("cmd"), OptOutput(&out), OptErrorHandling(flag.ContinueOnError))
+	root.ArgsVar(ArgsBetween(1, 3, &args), "[file...]", "", predict.OptValues("a", "b"))
+
+	assert.NoError(t, root.ParseArgs("cmd", "a", "b"))
+	assert.Equal(t, ArgsStr{"a", "b"}, args)
+
+	err := root.ParseArgs("cmd", "a", "b", "a", "b")
+	assert.EqualError(t, err, "cmd: bad positional args: expected 1 to 3 arguments, got 4")
+	var e *ArgsError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "b", e.Arg)
+	assert.Empty(t, args, "reset before parsing")
+
+	assert.True(t, errors.Is(root.ParseArgs("cmd", "-h"), flag.ErrHelp))
+	assert.Contains(t, out.String(), "Usage: cmd [file...] (1 to 3 arguments)\n")
+
+	assert.Equal(t, []string{"a", "b"}, root.completeLine(""))
+}
+
+func TestCmd_parseTwice(t *testing.T) {
+	t.Parallel()
+
+	root := newTestCmd()
+	require.NoError(t, root.ParseArgs("cmd", "sub1", "sub1", "-flag0", "-flag1", "value1", "-flag11", "value11", "arg1", "arg2"))
+	assert.True(t, root.sub11.Parsed())
+	assert.Equal(t, []string{"arg1", "arg2"}, *root.sub11Args)
+
+	require.NoError(t, root.ParseArgs("cmd", "sub2", "arg"))
+	assert.False(t, root.sub1.Parsed())
+	assert.False(t, root.sub11.Parsed())
+	assert.True(t, root.sub2.Parsed())
+	assert.False(t, *root.rootFlag)
+	assert.Equal(t, "", *root.sub1Flag)
+	assert.Equal(t, "", *root.sub11Flag)
+	assert.Empty(t, *root.sub11Args)
+	assert.Equal(t, []string{"arg"}, []string(root.sub2Args))
+
+	// Arbitrary number of arguments should be accepted in each parse.
+	require.NoError(t, root.ParseArgs("cmd", "sub1", "sub1", "arg1"))
+	require.NoError(t, root.ParseArgs("cmd", "sub1", "sub1", "arg1", "arg2", "arg3"))
+	assert.Equal(t, []string{"arg1", "arg2", "arg3"}, *root.sub11Args)
+
+	// Exact number of arguments should still be required.
+	assert.Error(t, root.ParseArgs("cmd", "sub2", "arg1", "arg2"))
+
+	root.Reset()
+	assert.False(t, root.sub11.Parsed())
+	assert.Empty(t, *root.sub11Args)
+
+	// Flags that were set in a previous parse are not reported as set.
+	require.NoError(t, root.ParseArgs("cmd", "sub1", "sub1", "-flag0", "-flag11", "value11"))
+	assert.Equal(t, 2, root.sub11.NFlag())
+	require.NoError(t, root.ParseArgs("cmd", "sub1", "sub1", "-flag11", "value11"))
+	assert.Equal(t, 1, root.sub11.NFlag())
+	assert.False(t, *root.rootFlag)
+}
+
+func TestCmd_parseConcurrently(t *testing.T) {
+	t.Parallel()
+
+	// Concurrent parsing is serialized, and is checked by the race detector.
+	root := newTestCmd()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, root.ParseArgs("cmd", "sub1", "sub1", "-flag1", "value", "arg"))
+			assert.NoError(t, root.ParseString("sub2 one"))
+			root.Reset()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCmd_ParseString(t *testing.T) {
+	t.Parallel()
+
+	t.Run("parse", func(t *testing.T) {
+		root := newTestCmd()
+		require.NoError(t, root.ParseString(`sub1 sub1 -flag1 'hello world' "a b" c\ d`))
+		assert.Equal(t, "hello world", *root.sub1Flag)
+		assert.Equal(t, []string{"a b", "c d"}, *root.sub11Args)
+	})
+
+	t.Run("expand env", func(t *testing.T) {
+		var out bytes.Buffer
+		root := New(OptErrorHandling(flag.ContinueOnError), OptOutput(&out), OptExpandEnv(func(name string) string {
+			return map[string]string{"NAME": "value"}[name]
+		}))
+		args := root.Args("", "")
+		require.NoError(t, root.ParseString(`$NAME '$NAME'`))
+		assert.Equal(t, []string{"value", "$NAME"}, *args)
+	})
+
+	tests := []struct {
+		line   string
+		column int
+	}{
+		{line: `sub1 sub1 'unterminated`, column: 11},
+		{line: `sub1  sub3`, column: 7},
+		{line: `sub1`, column: 5},
+		{line: `sub1 sub2 -flag12 x -no-such-flag`, column: 21},
+		{line: `sub1 sub2 -flag12 x arg`, column: 21},
+		{line: `sub2 'arg 1' arg2`, column: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			root := newTestCmd()
+			err := root.ParseString(tt.line)
+			var lineErr *LineError
+			if assert.True(t, errors.As(err, &lineErr), "got: %v", err) {
+				assert.Equal(t, tt.line, lineErr.Line)
+				assert.Equal(t, tt.column, lineErr.Column, "got: %v", err)
+			}
+		})
+	}
+}
+
+func TestCmd_exitOnError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default error output", func(t *testing.T) {
+		var (
+			out  bytes.Buffer
+			code = -1
+		)
+		root := New(OptName("cmd"), OptOutput(&out), OptExit(func(c int) { code = c }))
+		root.SubCommand("sub", "")
+
+		assert.Error(t, root.ParseArgs("cmd", "sub3"))
+		assert.Equal(t, ExitUsage, code)
+		assert.Equal(t, "invalid command: sub3\n", out.String())
+	})
+
+	t.Run("error output", func(t *testing.T) {
+		var (
+			out, errOut bytes.Buffer
+			code        = -1
+		)
+		root := New(OptName("cmd"), OptOutput(&out), OptErrOutput(&errOut), OptExit(func(c int) { code = c }))
+		root.SubCommand("sub", "")
+
+		assert.Error(t, root.ParseArgs("cmd"))
+		assert.Equal(t, ExitUsage, code)
+		assert.Contains(t, out.String(), "Usage: cmd [sub]")
+		assert.Equal(t, "must provide sub command\n", errOut.String())
+	})
+}
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"flag"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/posener/complete/v2"
 )
@@ -13,16 +16,25 @@ func (c *completer) SubCmdList() []string {
 }
 
 func (c *completer) SubCmdGet(name string) complete.Completer {
-	if c.sub[name] == nil {
+	sub := (*SubCmd)(c).subCmd(name)
+	if sub == nil {
 		return nil
 	}
-	return (*completer)(c.sub[name])
+	return (*completer)(sub)
 }
 
 func (c *completer) FlagList() []string {
+	// The completion lists the flags of all the commands in the command line. The sub commands
+	// inherit the flags of their parent, so the parent should not list them again.
 	if len(c.sub) != 0 {
 		return nil
 	}
+	return c.flagNames()
+}
+
+// flagNames returns the names of the flags of the command, including the inherited flags.
+func (c *completer) flagNames() []string {
+	(*SubCmd)(c).syncFlags()
 	var flags []string
 	c.FlagSet.VisitAll(func(f *flag.Flag) {
 		flags = append(flags, f.Name)
@@ -31,6 +43,7 @@ func (c *completer) FlagList() []string {
 }
 
 func (c *completer) FlagGet(flag string) complete.Predictor {
+	(*SubCmd)(c).syncFlags()
 	f := c.FlagSet.Lookup(flag)
 	if f == nil {
 		return nil
@@ -42,11 +55,55 @@ func (c *completer) FlagGet(flag string) complete.Predictor {
 }
 
 func (c *completer) ArgsGet() complete.Predictor {
-	if c.args.predict.Predictor != nil {
-		return c.args.predict
+	// The completion falls back to the positional arguments of the parent commands in the command
+	// line, but they apply only when the parent is the invoked command. A command with sub commands
+	// is completed only as a parent, since then only its sub commands are completed.
+	if len(c.sub) != 0 {
+		return nil
 	}
-	if p, ok := c.args.value.(complete.Predictor); ok {
-		return p
+	// Skip the command name and the sub commands names.
+	skip := 1
+	for p := c.parent; p != nil; p = p.parent {
+		skip++
+	}
+	return c.argsPredictor(compLineArgs(skip))
+}
+
+// argsPredictor returns the predictor of the positional argument that follows the given arguments
+// of the command.
+func (c *completer) argsPredictor(args []string) complete.Predictor {
+	if c.args != nil {
+		if named, ok := c.args.value.(*namedArgs); ok {
+			return named.predictor((*SubCmd)(c).argIndex(args))
+		}
+		if c.args.predict.Predictor != nil {
+			return c.args.predict
+		}
+		value := unwrapArgs(c.args.value)
+		// Values such as `ArgsOf` implement the predictor interface even without a predictor.
+		if op, ok := value.(interface{ hasPredictor() bool }); ok && !op.hasPredictor() {
+			value = nil
+		}
+		if p, ok := value.(complete.Predictor); ok {
+			return p
+		}
 	}
 	return nil
 }
+
+// compLineArgs returns the arguments of the command line that is completed by the shell, before
+// the completed word, without the given number of arguments of the command and sub commands names.
+func compLineArgs(skip int) []string {
+	line := os.Getenv("COMP_LINE")
+	if point, err := strconv.Atoi(os.Getenv("COMP_POINT")); err == nil && point >= 0 && point <= len(line) {
+		line = line[:point]
+	}
+	args := strings.Fields(line)
+	if len(args) > 0 && !strings.HasSuffix(line, " ") {
+		args = args[:len(args)-1]
+	}
+	if skip > len(args) {
+		return nil
+	}
+	return args[skip:]
+}
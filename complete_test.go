@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
 )
 
 func TestComplete(t *testing.T) {
@@ -31,3 +32,35 @@ func TestComplete(t *testing.T) {
 		})
 	}
 }
+
+func TestComplete_leafArgs(t *testing.T) {
+	t.Parallel()
+
+	root := New(OptName("cmd"))
+	root.Args("", "", predict.OptValues("root"))
+	root.Bool("verbose", false, "")
+	root.SubCommand("leaf", "").Bool("flag", false, "")
+	root.SubCommand("args", "").Args("", "", predict.OptValues("sub"))
+	comp := (*completer)(root.SubCmd)
+
+	// Positional arguments of the root command don't apply to its sub commands.
+	complete.Test(t, comp, "leaf ", []string{"-flag", "-verbose", "-h"})
+	complete.Test(t, comp, "args ", []string{"-h", "-verbose", "sub"})
+	// Inherited flags are listed once.
+	complete.Test(t, comp, "leaf -", []string{"-flag", "-verbose", "-h"})
+}
+
+func BenchmarkComplete(b *testing.B) {
+	root := newBenchCmd(10, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var c complete.Completer = (*completer)(root.SubCmd)
+		for len(c.SubCmdList()) > 0 {
+			c = c.SubCmdGet("sub9")
+		}
+		if len(c.FlagList()) != 4 {
+			b.Fatal("expected 4 flags")
+		}
+		c.FlagGet("flag0").Predict("")
+	}
+}
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Exit codes that are used when the program exits due to an error, following the sysexits
+// convention.
+const (
+	// ExitFailure is the exit code for a general failure.
+	ExitFailure = 1
+	// ExitUsage is the exit code for errors in the command line usage, such as unknown sub command
+	// or bad flags.
+	ExitUsage = 64
+)
+
+// ExitCoder is an error that defines the exit code of the program.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// ExitError is an error with an exit code. It can be returned from the program logic to define the
+// exit code in the `Exit` method.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode implements the ExitCoder interface.
+func (e *ExitError) ExitCode() int { return e.Code }
+
+// UnknownCommandError is returned when a command is called with an unknown sub command name.
+type UnknownCommandError struct {
+	// Path is the command that was called.
+	Path string
+	// Name is the unknown sub command name.
+	Name string
+	// Suggestions are the sub command names which are similar to the unknown name.
+	Suggestions []string
+
+	position
+}
+
+func (e *UnknownCommandError) usageError() {}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("invalid command: %s", e.Name)
+}
+
+// MissingSubCommandError is returned when a command that has sub commands is called without a
+// sub command.
+type MissingSubCommandError struct {
+	// Path is the command that was called.
+	Path string
+	// Suggestions are the sub command names of the command.
+	Suggestions []string
+
+	position
+}
+
+func (e *MissingSubCommandError) usageError() {}
+
+func (e *MissingSubCommandError) Error() string {
+	return "must provide sub command"
+}
+
+// FlagError is returned when the flags of a command can't be parsed.
+type FlagError struct {
+	// Path is the command that was called.
+	Path string
+	// Arg is the argument that failed, either the flag or its value.
+	Arg string
+	// Err is the error from the flag parsing. It is a `*CheckError` when the flag value does not
+	// fit the predicted values.
+	Err error
+	// Suggestions are the flag names which are similar to an unknown flag.
+	Suggestions []string
+
+	position
+}
+
+func (e *FlagError) usageError() {}
+
+func (e *FlagError) Error() string {
+	return fmt.Sprintf("%s: bad flags: %v", e.Path, e.Err)
+}
+
+func (e *FlagError) Unwrap() error { return e.Err }
+
+// ArgsError is returned when the positional arguments of a command are invalid.
+type ArgsError struct {
+	// Path is the command that was called.
+	Path string
+	// Arg is the positional argument that failed. It is empty when the failure is not related to
+	// a specific argument.
+	Arg string
+	// Err is the underlying error. It is a `*CheckError` when the argument does not fit the
+	// predicted values.
+	Err error
+
+	position
+}
+
+func (e *ArgsError) usageError() {}
+
+func (e *ArgsError) Error() string {
+	return fmt.Sprintf("%s: bad positional args: %v", e.Path, e.Err)
+}
+
+func (e *ArgsError) Unwrap() error { return e.Err }
+
+// CheckError is returned when a flag value or a positional argument does not fit its predicted
+// values, as defined by the `predict.OptCheck` option.
+type CheckError struct {
+	// Path is the command that was called.
+	Path string
+	// Flag is the name of the checked flag. It is empty for positional arguments.
+	Flag string
+	// Arg is the checked value.
+	Arg string
+	// Err is the error of the check.
+	Err error
+	// Suggestions are the allowed values.
+	Suggestions []string
+}
+
+func (e *CheckError) Error() string {
+	if e.Flag != "" {
+		return fmt.Sprintf("invalid value %q for flag -%s: %v", e.Arg, e.Flag, e.Err)
+	}
+	return fmt.Sprintf("arg %q: %v", e.Arg, e.Err)
+}
+
+func (e *CheckError) Unwrap() error { return e.Err }
+
+// Kinds of errors in the `Kind` field of `JSONError`.
+const (
+	KindUnknownCommand    = "unknown_command"
+	KindMissingSubCommand = "missing_sub_command"
+	KindFlag              = "flag"
+	KindArgs              = "args"
+	KindCheck             = "check"
+	KindSyntax            = "syntax"
+	KindError             = "error"
+)
+
+// JSONError is the JSON representation of an error, which is printed when the `OptErrJSON` option
+// is used. The field names are part of the API and will not change. For example:
+//
+// 	{"kind":"unknown_command","message":"invalid command: isntall","command":"cmd","arg":"isntall","suggestions":["install"]}
+type JSONError struct {
+	// Kind is the kind of the error, one of the `Kind*` constants. Errors that are not related to
+	// the command line, such as errors from the program logic, are of kind `KindError`.
+	Kind string `json:"kind"`
+	// Message is the error message.
+	Message string `json:"message"`
+	// Command is the path of the command that failed, for example "cmd sub".
+	Command string `json:"command,omitempty"`
+	// Arg is the command line argument that caused the error.
+	Arg string `json:"arg,omitempty"`
+	// Column is the 1-based column of the error in the command line, for errors of `ParseString`
+	// and of the interactive shell.
+	Column int `json:"column,omitempty"`
+	// Suggestions are possible corrections for the error: similar sub commands or flags, or the
+	// allowed values.
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// NewJSONError returns the JSON representation of an error.
+func NewJSONError(err error) *JSONError {
+	e := &JSONError{Kind: KindError, Message: err.Error()}
+	var (
+		unknown *UnknownCommandError
+		missing *MissingSubCommandError
+		flagErr *FlagError
+		argsErr *ArgsError
+		check   *CheckError
+		line    *LineError
+	)
+	switch {
+	case errors.As(err, &check):
+		e.Kind, e.Command, e.Arg, e.Suggestions = KindCheck, check.Path, check.Arg, check.Suggestions
+	case errors.As(err, &flagErr):
+		e.Kind, e.Command, e.Arg, e.Suggestions = KindFlag, flagErr.Path, flagErr.Arg, flagErr.Suggestions
+	case errors.As(err, &argsErr):
+		e.Kind, e.Command, e.Arg = KindArgs, argsErr.Path, argsErr.Arg
+	case errors.As(err, &unknown):
+		e.Kind, e.Command, e.Arg, e.Suggestions = KindUnknownCommand, unknown.Path, unknown.Name, unknown.Suggestions
+	case errors.As(err, &missing):
+		e.Kind, e.Command, e.Suggestions = KindMissingSubCommand, missing.Path, missing.Suggestions
+	case errors.As(err, &line):
+		e.Kind = KindSyntax
+	}
+	if errors.As(err, &line) {
+		e.Column = line.Column
+	}
+	return e
+}
+
+// printError prints an error to the error output, as text or as JSON according to the `OptErrJSON`
+// option.
+func (c *Cmd) printError(err error) {
+	if c.errJSON {
+		writeJSON(c.errOutput, NewJSONError(err))
+		return
+	}
+	fmt.Fprintln(c.errOutput, err)
+	if c.errCaret {
+		c.printCaret(err)
+	}
+}
+
+// printCaret prints the command line that caused the error, with a marker under the offending
+// argument. It prints nothing if the error is not related to a position in the command line.
+func (c *Cmd) printCaret(err error) {
+	var (
+		line   *LineError
+		posErr positioned
+	)
+	switch {
+	case errors.As(err, &line):
+		width := line.width
+		if width < 1 {
+			width = 1
+		}
+		printMarker(c.errOutput, line.Line, line.Column-1, width)
+	case errors.As(err, &posErr) && len(c.line) > 0:
+		i := posErr.index(c.line)
+		if i < 0 || i > len(c.line) {
+			return
+		}
+		quoted := make([]string, len(c.line))
+		for j, arg := range c.line {
+			quoted[j] = quoteArg(arg)
+		}
+		offset, width := len(strings.Join(quoted[:i], " ")), 1
+		if i > 0 {
+			offset++ // The space before the argument.
+		}
+		if i < len(quoted) {
+			width = len(quoted[i])
+		}
+		printMarker(c.errOutput, strings.Join(quoted, " "), offset, width)
+	}
+}
+
+// printMarker prints a line and a marker of the given width under the given offset in the line.
+func printMarker(w io.Writer, line string, offset, width int) {
+	fmt.Fprintln(w, line)
+	fmt.Fprintln(w, strings.Repeat(" ", offset)+strings.Repeat("^", width))
+}
+
+// quoteArg quotes an argument of the command line if it is empty or contains spaces or quotes.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func writeJSON(w io.Writer, e *JSONError) {
+	enc := json.NewEncoder(w)
+	// The output is a stable format, and the messages contain characters such as '>'.
+	enc.SetEscapeHTML(false)
+	// Only writing can fail, since the struct contains only strings and ints. Write errors are
+	// ignored as in the other error outputs.
+	enc.Encode(e)
+}
+
+// usageError is an error in the command line usage.
+type usageError interface {
+	error
+	usageError()
+}
+
+// exitCode returns the exit code for a given error: 0 for no error or a help request, the code of
+// the first matching `OptExitCode` option, the code of an `ExitCoder`, `ExitUsage` for errors in the
+// command line usage and `ExitFailure` otherwise.
+func (c *Cmd) exitCode(err error) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	for _, ec := range c.exitCodes {
+		if errors.As(err, ec.target) {
+			return ec.code
+		}
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	var usage usageError
+	if errors.As(err, &usage) {
+		return ExitUsage
+	}
+	return ExitFailure
+}
+
+// checkExitCodeTarget panics if the target can't be used with `errors.As`, such that a wrong
+// option fails when the command is defined and not when the program exits.
+func checkExitCodeTarget(target interface{}) {
+	v := reflect.ValueOf(target)
+	if target == nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		panic(fmt.Sprintf("exit code target must be a non-nil pointer, got %T", target))
+	}
+	if e := v.Type().Elem(); e.Kind() != reflect.Interface && !e.Implements(errorType) {
+		panic(fmt.Sprintf("exit code target must point to an interface or to an error type, got %T", target))
+	}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// position is the position of the argument that caused an error in the command line. Since each
+// command parses a suffix of the command line, the argument is identified by its distance from
+// the end of the command line: The last argument has distance 1, and distance 0 refers to the end
+// of the command line.
+type position struct {
+	fromEnd int
+}
+
+func (p position) index(args []string) int {
+	return len(args) - p.fromEnd
+}
+
+// positioned is an error that holds a position.
+type positioned interface {
+	error
+	index(args []string) int
+}
+
+// flagError returns the error of parsing the given arguments with the command flag set.
+func (c *SubCmd) flagError(args []string, err error) *FlagError {
+	// The flag set stops after the argument that failed, which is either the flag or its value.
+	remaining := len(c.FlagSet.Args())
+	e := &FlagError{Path: c.name, Err: err, position: position{fromEnd: remaining + 1}}
+	i := len(args) - remaining - 1
+	if i < 0 || err == flag.ErrHelp {
+		return e
+	}
+	e.Arg = args[i]
+
+	// Find the flag name and value to check if the flag value failed the predict check.
+	name, value := strings.TrimLeft(args[i], "-"), ""
+	if eq := strings.Index(name, "="); eq >= 0 {
+		name, value = name[:eq], name[eq+1:]
+	} else if i > 0 && !strings.HasPrefix(args[i], "-") {
+		name, value = strings.TrimLeft(args[i-1], "-"), args[i]
+	}
+	f := c.Lookup(name)
+	if f == nil {
+		var names []string
+		c.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+		for _, name := range similar(name, names) {
+			e.Suggestions = append(e.Suggestions, "-"+name)
+		}
+		return e
+	}
+	if checker, ok := f.Value.(interface{ Check(string) error }); ok {
+		if checkErr := checker.Check(value); checkErr != nil {
+			e.Err = &CheckError{Path: c.name, Flag: name, Arg: value, Err: checkErr, Suggestions: predictedValues(f.Value, nil)}
+		}
+	}
+	return e
+}
+
+// similar returns the options which are similar to the given name: options that the name is their
+// prefix, or that are in a small edit distance from it.
+func similar(name string, options []string) []string {
+	var similar []string
+	for _, option := range options {
+		if strings.HasPrefix(option, name) || editDistance(name, option) <= 2 {
+			similar = append(similar, option)
+		}
+	}
+	return similar
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/posener/complete/v2/predict"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	newRoot := func() *Cmd {
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		sub := root.SubCommand("sub", "")
+		sub.String("flag", "", "", predict.OptValues("foo", "bar"), predict.OptCheck())
+		sub.Int("int", 0, "")
+		args := make(ArgsStr, 0, 1)
+		sub.ArgsVar(&args, "[arg]", "", predict.OptValues("one", "two"), predict.OptCheck())
+		root.SubCommand("leaf", "")
+		return root
+	}
+
+	t.Run("unknown command", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "nope")
+		var e *UnknownCommandError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "cmd", e.Path)
+		assert.Equal(t, "nope", e.Name)
+		assert.EqualError(t, err, "invalid command: nope")
+	})
+
+	t.Run("missing sub command", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd")
+		var e *MissingSubCommandError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "cmd", e.Path)
+		assert.EqualError(t, err, "must provide sub command")
+	})
+
+	t.Run("bad flag", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "sub", "-int", "x")
+		var e *FlagError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "cmd sub", e.Path)
+		assert.Equal(t, "x", e.Arg)
+		assert.False(t, errors.As(err, new(*CheckError)))
+		assert.EqualError(t, err, `cmd > cmd sub: bad flags: invalid value "x" for flag -int: bad value for int flag`)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "sub", "-int=1", "-nope")
+		var e *FlagError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "-nope", e.Arg)
+	})
+
+	t.Run("help", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "sub", "-h")
+		assert.True(t, errors.Is(err, flag.ErrHelp))
+	})
+
+	t.Run("flag check", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"cmd", "sub", "-flag", "baz"},
+			{"cmd", "sub", "-flag=baz"},
+		} {
+			err := newRoot().ParseArgs(args...)
+			var e *CheckError
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, "cmd sub", e.Path)
+			assert.Equal(t, "flag", e.Flag)
+			assert.Equal(t, "baz", e.Arg)
+			assert.EqualError(t, err, `cmd > cmd sub: bad flags: invalid value "baz" for flag -flag: not in allowed values: foo,bar`)
+		}
+	})
+
+	t.Run("bad positional args", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "sub", "one", "two")
+		var e *ArgsError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "cmd sub", e.Path)
+		assert.Equal(t, "", e.Arg)
+		assert.False(t, errors.As(err, new(*CheckError)))
+	})
+
+	t.Run("unexpected positional args", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "leaf", "arg")
+		var e *ArgsError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "cmd leaf", e.Path)
+		assert.Equal(t, "arg", e.Arg)
+	})
+
+	t.Run("positional args check", func(t *testing.T) {
+		err := newRoot().ParseArgs("cmd", "sub", "three")
+		var e *CheckError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "cmd sub", e.Path)
+		assert.Equal(t, "", e.Flag)
+		assert.Equal(t, "three", e.Arg)
+		assert.True(t, errors.As(err, new(*ArgsError)))
+		assert.EqualError(t, err, `cmd > cmd sub: bad positional args: arg "three": not in allowed values: one,two`)
+	})
+}
+
+func TestCmd_Exit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		options  []optionRoot
+		wantCode int
+		wantOut  string
+	}{
+		{name: "no error", err: nil, wantCode: 0},
+		{name: "help", err: flag.ErrHelp, wantCode: 0},
+		{name: "general error", err: errors.New("failed"), wantCode: ExitFailure, wantOut: "failed\n"},
+		{name: "usage error", err: &UnknownCommandError{Name: "sub"}, wantCode: ExitUsage, wantOut: "invalid command: sub\n"},
+		{name: "exit coder", err: &ExitError{Code: 3, Err: errors.New("failed")}, wantCode: 3, wantOut: "failed\n"},
+		{
+			name:     "exit code option",
+			err:      &UnknownCommandError{Name: "sub"},
+			options:  []optionRoot{OptExitCode(new(*FlagError), 5), OptExitCode(new(*UnknownCommandError), 127)},
+			wantCode: 127,
+			wantOut:  "invalid command: sub\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				out  bytes.Buffer
+				code = -1
+			)
+			root := New(append(tt.options, OptOutput(&out), OptExit(func(c int) { code = c }))...)
+			root.Exit(tt.err)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantOut, out.String())
+		})
+	}
+
+	t.Run("invalid exit code targets", func(t *testing.T) {
+		assert.Panics(t, func() { New(OptExitCode(nil, 1)) })
+		assert.Panics(t, func() { New(OptExitCode(FlagError{}, 1)) })
+		assert.Panics(t, func() { New(OptExitCode((**FlagError)(nil), 1)) })
+		assert.Panics(t, func() { New(OptExitCode(new(string), 1)) })
+		assert.NotPanics(t, func() { New(OptExitCode(new(ExitCoder), 1)) })
+	})
+
+	t.Run("parse help", func(t *testing.T) {
+		code := -1
+		root := New(OptOutput(ioutil.Discard), OptExit(func(c int) { code = c }))
+		root.SubCommand("sub", "")
+		root.ParseArgs("cmd", "-h")
+		assert.Equal(t, 0, code)
+	})
+}
+
+func TestJSONError(t *testing.T) {
+	t.Parallel()
+
+	newRoot := func() *Cmd {
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		sub := root.SubCommand("install", "")
+		sub.String("flag", "", "", predict.OptValues("foo", "bar"), predict.OptCheck())
+		sub.Int("int", 0, "")
+		root.SubCommand("leaf", "")
+		return root
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want JSONError
+	}{
+		{
+			name: "unknown command",
+			err:  newRoot().ParseArgs("cmd", "isntall"),
+			want: JSONError{Kind: KindUnknownCommand, Message: "invalid command: isntall", Command: "cmd", Arg: "isntall", Suggestions: []string{"install"}},
+		},
+		{
+			name: "missing sub command",
+			err:  newRoot().ParseArgs("cmd"),
+			want: JSONError{Kind: KindMissingSubCommand, Message: "must provide sub command", Command: "cmd", Suggestions: []string{"install", "leaf"}},
+		},
+		{
+			name: "unknown flag",
+			err:  newRoot().ParseArgs("cmd", "install", "-flg"),
+			want: JSONError{Kind: KindFlag, Message: "cmd > cmd install: bad flags: flag provided but not defined: -flg", Command: "cmd install", Arg: "-flg", Suggestions: []string{"-flag"}},
+		},
+		{
+			name: "check",
+			err:  newRoot().ParseArgs("cmd", "install", "-flag", "baz"),
+			want: JSONError{Kind: KindCheck, Message: `cmd > cmd install: bad flags: invalid value "baz" for flag -flag: not in allowed values: foo,bar`, Command: "cmd install", Arg: "baz", Suggestions: []string{"foo", "bar"}},
+		},
+		{
+			name: "args",
+			err:  newRoot().ParseArgs("cmd", "leaf", "arg"),
+			want: JSONError{Kind: KindArgs, Message: "cmd > cmd leaf: bad positional args: positional args not expected, got [arg]", Command: "cmd leaf", Arg: "arg"},
+		},
+		{
+			name: "line",
+			err:  newRoot().ParseString("leaf   arg"),
+			want: JSONError{Kind: KindArgs, Message: "column 8: cmd > cmd leaf: bad positional args: positional args not expected, got [arg]", Command: "cmd leaf", Arg: "arg", Column: 8},
+		},
+		{
+			name: "syntax",
+			err:  newRoot().ParseString(`leaf "arg`),
+			want: JSONError{Kind: KindSyntax, Message: "column 6: unterminated \" quote", Column: 6},
+		},
+		{
+			name: "other",
+			err:  errors.New("failed"),
+			want: JSONError{Kind: KindError, Message: "failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, &tt.want, NewJSONError(tt.err))
+		})
+	}
+
+	t.Run("output", func(t *testing.T) {
+		var out bytes.Buffer
+		root := New(OptName("cmd"), OptOutput(&out), OptErrJSON(), OptExit(func(int) {}))
+		root.SubCommand("install", "")
+		root.ParseArgs("cmd", "isntall")
+		assert.Contains(t, out.String(), `{"kind":"unknown_command","message":"invalid command: isntall","command":"cmd","arg":"isntall","suggestions":["install"]}`+"\n")
+	})
+
+	t.Run("output has only json", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"cmd"},
+			{"cmd", "isntall"},
+			{"cmd", "install", "-flg"},
+			{"cmd", "install", "-int", "x"},
+		} {
+			var out bytes.Buffer
+			root := New(OptName("cmd"), OptOutput(&out), OptErrJSON(), OptExit(func(int) {}))
+			sub := root.SubCommand("install", "")
+			sub.Int("int", 0, "")
+			root.ParseArgs(args...)
+			var got JSONError
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &got), "output of %v: %s", args, out.String())
+		}
+	})
+
+	t.Run("not html escaped", func(t *testing.T) {
+		var out bytes.Buffer
+		root := New(OptName("cmd"), OptOutput(&out), OptErrJSON(), OptExit(func(int) {}))
+		sub := root.SubCommand("install", "")
+		sub.Int("int", 0, "")
+		root.ParseArgs("cmd", "install", "-int", "x")
+		assert.Contains(t, out.String(), `"message":"cmd > cmd install: `)
+	})
+
+	t.Run("help", func(t *testing.T) {
+		var out bytes.Buffer
+		root := New(OptName("cmd"), OptOutput(&out), OptErrJSON(), OptExit(func(int) {}))
+		sub := root.SubCommand("install", "")
+		sub.Int("int", 0, "")
+		root.ParseArgs("cmd", "install", "-h")
+		assert.Contains(t, out.String(), "Usage: cmd install [flags]")
+		assert.Contains(t, out.String(), "-int")
+	})
+}
+
+func TestCmd_errCaret(t *testing.T) {
+	t.Parallel()
+
+	newRoot := func(out *bytes.Buffer) *Cmd {
+		root := New(OptName("cmd"), OptOutput(out), OptErrCaret(), OptExit(func(int) {}))
+		sub := root.SubCommand("sub", "")
+		sub.String("flag", "", "", predict.OptValues("foo", "bar"), predict.OptCheck())
+		args := make(ArgsInt, 0, 2)
+		sub.ArgsVar(&args, "[int] [int]", "")
+		return root
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "unknown command",
+			args: []string{"cmd", "nope"},
+			want: "cmd nope\n    ^^^^\n",
+		},
+		{
+			name: "missing sub command",
+			args: []string{"cmd"},
+			want: "cmd\n    ^\n",
+		},
+		{
+			name: "flag value",
+			args: []string{"cmd", "sub", "-flag", "baz", "1", "2"},
+			want: "cmd sub -flag baz 1 2\n              ^^^\n",
+		},
+		{
+			name: "quoted",
+			args: []string{"cmd", "sub", "-flag", "b z", "1", "2"},
+			want: "cmd sub -flag 'b z' 1 2\n              ^^^^^\n",
+		},
+		{
+			name: "args set index",
+			args: []string{"cmd", "sub", "1", "x"},
+			want: "cmd sub 1 x\n          ^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			root := newRoot(&out)
+			root.ParseArgs(tt.args...)
+			assert.True(t, strings.HasSuffix(out.String(), tt.want), "got:\n%s", out.String())
+		})
+	}
+
+	t.Run("string", func(t *testing.T) {
+		var out bytes.Buffer
+		root := newRoot(&out)
+		root.ParseString(`sub  1   "x y"`)
+		assert.True(t, strings.HasSuffix(out.String(), "sub  1   \"x y\"\n         ^^^^^\n"), "got:\n%s", out.String())
+	})
+}
@@ -110,3 +110,30 @@ func Example_argsFn() {
 	fmt.Println(src, dst)
 	// Output: from.txt to.txt
 }
+
+// An example of defining named positional arguments. The usage string of the positional arguments
+// is generated from the names, and each argument is parsed to the type of its variable.
+func Example_argsNamed() {
+	// Should be defined in global `var`.
+	var (
+		root = cmd.New()
+		// Define variables that will hold the command line positional arguments.
+		src   string
+		count = 1
+		rest  []string
+	)
+
+	// Should be in `init()`.
+	// Register the variables in the root command in the order of the positional arguments.
+	root.Arg("src", &src, "source file")
+	root.ArgOptional("count", &count, "number of copies")
+	root.ArgVariadic("dst", &rest, "destination files")
+
+	// Should be in `main()`.
+	root.ParseArgs("cmd", "from.txt", "2", "a.txt", "b.txt")
+
+	// Test:
+
+	fmt.Println(src, count, rest)
+	// Output: from.txt 2 [a.txt b.txt]
+}
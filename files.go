@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/posener/complete/v2/predict"
+)
+
+// ArgsFiles are file path positional arguments. When parsed, it checks that the files exist and
+// are readable, and that their names match the pattern. The path "-" stands for the standard input,
+// or for the standard output when Output is set. If Paths is created with cap > 0, it will be used
+// to define the number of required arguments. It completes file names that match the pattern.
+//
+// Parsing does not open the files, such that it has no side effects. The files are opened by the
+// `Open` or `Create` methods, and the handler must close them. For example:
+//
+// 	args := cmd.ArgsFiles{Pattern: "*.go"}
+// 	root.ArgsVar(&args, "[file...]", "go files to process")
+//
+// 	...
+//
+// 	readers, err := args.Open()
+// 	if err != nil {
+// 		...
+// 	}
+// 	for _, r := range readers {
+// 		defer r.Close()
+// 		...
+// 	}
+type ArgsFiles struct {
+	// Paths are the given paths.
+	Paths []string
+	// Pattern is a pattern, as in `filepath.Match`, that the base names of the files must match.
+	// If it is empty, any file name is allowed.
+	Pattern string
+	// Output defines the files as output files, which are created by the `Create` method. When
+	// parsed, it only checks that their directories exist and that they are not directories.
+	Output bool
+	// Stdin is the reader for the path "-". The default is `os.Stdin`.
+	Stdin io.Reader
+	// Stdout is the writer for the path "-" when Output is set. The default is `os.Stdout`.
+	Stdout io.Writer
+}
+
+// Set implements the ArgsValue interface.
+func (a *ArgsFiles) Set(args []string) error {
+	return setOf(&a.Paths, args, "file", func(path string) (string, error) { return path, a.check(path) })
+}
+
+// check checks a path according to the configuration.
+func (a *ArgsFiles) check(path string) error {
+	if path == "-" {
+		return nil
+	}
+	if err := checkPattern(a.Pattern, path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if a.Output {
+		switch {
+		case err == nil && info.IsDir():
+			return fmt.Errorf("is a directory")
+		case err == nil || !os.IsNotExist(err):
+			return err
+		}
+		// The file will be created, check that its directory exists.
+		info, err = os.Stat(filepath.Dir(path))
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("not a directory: %s", filepath.Dir(path))
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("is a directory")
+	}
+	// Opening the file checks that it is readable.
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Open opens the files for reading, in the order of Paths. The path "-" is opened as the standard
+// input. When it fails, the files that it opened are closed.
+func (a *ArgsFiles) Open() ([]io.ReadCloser, error) {
+	var readers []io.ReadCloser
+	for _, path := range a.Paths {
+		if path == "-" {
+			var stdin io.Reader = os.Stdin
+			if a.Stdin != nil {
+				stdin = a.Stdin
+			}
+			readers = append(readers, ioutil.NopCloser(stdin))
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll(readers, nil)
+			return nil, err
+		}
+		readers = append(readers, f)
+	}
+	return readers, nil
+}
+
+// Create creates or truncates the files for writing, in the order of Paths. The path "-" is opened
+// as the standard output. When it fails, the files that it created are closed.
+func (a *ArgsFiles) Create() ([]io.WriteCloser, error) {
+	var writers []io.WriteCloser
+	for _, path := range a.Paths {
+		if path == "-" {
+			var stdout io.Writer = os.Stdout
+			if a.Stdout != nil {
+				stdout = a.Stdout
+			}
+			writers = append(writers, nopWriteCloser{stdout})
+			continue
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			closeAll(nil, writers)
+			return nil, err
+		}
+		writers = append(writers, f)
+	}
+	return writers, nil
+}
+
+// Predict implements the complete.Predictor interface.
+func (a *ArgsFiles) Predict(prefix string) []string {
+	return predict.Files(orAny(a.Pattern)).Predict(prefix)
+}
+
+// ArgsDirs are directory path positional arguments. When parsed, it checks that the directories
+// exist and that their names match the pattern. If Paths is created with cap > 0, it will be used
+// to define the number of required arguments. It completes directory names that match the pattern.
+type ArgsDirs struct {
+	// Paths are the given paths.
+	Paths []string
+	// Pattern is a pattern, as in `filepath.Match`, that the base names of the directories must
+	// match. If it is empty, any directory name is allowed.
+	Pattern string
+}
+
+// Set implements the ArgsValue interface.
+func (a *ArgsDirs) Set(args []string) error {
+	return setOf(&a.Paths, args, "directory", func(path string) (string, error) { return path, a.check(path) })
+}
+
+// check checks a path according to the configuration.
+func (a *ArgsDirs) check(path string) error {
+	if err := checkPattern(a.Pattern, path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	return nil
+}
+
+// Predict implements the complete.Predictor interface.
+func (a *ArgsDirs) Predict(prefix string) []string {
+	return predict.Dirs(orAny(a.Pattern)).Predict(prefix)
+}
+
+// checkPattern checks that the base name of a path matches a pattern. An empty pattern matches
+// any path.
+func checkPattern(pattern, path string) error {
+	if pattern == "" {
+		return nil
+	}
+	ok, err := filepath.Match(pattern, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("does not match %s", pattern)
+	}
+	return nil
+}
+
+// orAny returns the pattern, or a pattern that matches any name if it is empty.
+func orAny(pattern string) string {
+	if pattern == "" {
+		return "*"
+	}
+	return pattern
+}
+
+func closeAll(readers []io.ReadCloser, writers []io.WriteCloser) {
+	for _, r := range readers {
+		r.Close()
+	}
+	for _, w := range writers {
+		w.Close()
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/posener/complete/v2/predict"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgsFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.go")
+	require.NoError(t, ioutil.WriteFile(a, []byte("a"), 0644))
+	require.NoError(t, ioutil.WriteFile(b, []byte("b"), 0644))
+
+	t.Run("exist", func(t *testing.T) {
+		var args ArgsFiles
+		require.NoError(t, args.Set([]string{a, b, "-"}))
+		assert.Equal(t, []string{a, b, "-"}, args.Paths)
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		var args ArgsFiles
+		err := args.Set([]string{a, filepath.Join(dir, "c")})
+		var argErr *ArgError
+		require.True(t, errors.As(err, &argErr))
+		assert.Equal(t, 1, argErr.Index)
+		assert.Contains(t, err.Error(), "invalid file positional argument at position 1")
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		var args ArgsFiles
+		assert.Error(t, args.Set([]string{dir}))
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		args := ArgsFiles{Pattern: "*.go"}
+		assert.NoError(t, args.Set([]string{b}))
+		err := args.Set([]string{a})
+		assert.EqualError(t, err, "invalid file positional argument at position 0 with value "+a+": does not match *.go")
+	})
+
+	t.Run("cap", func(t *testing.T) {
+		args := ArgsFiles{Paths: make([]string, 0, 2)}
+		assert.Error(t, args.Set([]string{a}))
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can read any file")
+		}
+		c := filepath.Join(dir, "c.txt")
+		require.NoError(t, ioutil.WriteFile(c, []byte("c"), 0200))
+		var args ArgsFiles
+		assert.Error(t, args.Set([]string{c}))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		args := ArgsFiles{Stdin: strings.NewReader("in")}
+		require.NoError(t, args.Set([]string{a, "-"}))
+		readers, err := args.Open()
+		require.NoError(t, err)
+		require.Len(t, readers, 2)
+		for i, want := range []string{"a", "in"} {
+			got, err := ioutil.ReadAll(readers[i])
+			require.NoError(t, err)
+			assert.Equal(t, want, string(got))
+			assert.NoError(t, readers[i].Close())
+		}
+	})
+
+	t.Run("output", func(t *testing.T) {
+		var stdout bytes.Buffer
+		c := filepath.Join(dir, "c.out")
+		args := ArgsFiles{Output: true, Stdout: &stdout}
+		require.NoError(t, args.Set([]string{c, a, "-"}))
+		// Parsing does not create or truncate the files.
+		_, err := os.Stat(c)
+		assert.True(t, os.IsNotExist(err))
+		got, err := ioutil.ReadFile(a)
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(got))
+
+		args.Paths = []string{c, "-"}
+		writers, err := args.Create()
+		require.NoError(t, err)
+		require.Len(t, writers, 2)
+		for _, w := range writers {
+			_, err := w.Write([]byte("out"))
+			require.NoError(t, err)
+			assert.NoError(t, w.Close())
+		}
+		got, err = ioutil.ReadFile(c)
+		require.NoError(t, err)
+		assert.Equal(t, "out", string(got))
+		assert.Equal(t, "out", stdout.String())
+
+		assert.Error(t, args.Set([]string{dir}))
+		assert.Error(t, args.Set([]string{filepath.Join(dir, "nope", "c.out")}))
+	})
+
+	t.Run("random args", func(t *testing.T) {
+		// Random args are parsed, and parsing output files must not truncate existing files.
+		args := ArgsFiles{Pattern: "*.txt", Output: true}
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		root.ArgsVar(&args, "[file...]", "", predict.OptValues(a))
+		r := rand.New(rand.NewSource(0))
+		for i := 0; i < 20; i++ {
+			require.NoError(t, root.ParseArgs(root.RandomArgs(r)...))
+		}
+		got, err := ioutil.ReadFile(a)
+		require.NoError(t, err)
+		assert.Equal(t, "a", string(got))
+	})
+
+	t.Run("predict", func(t *testing.T) {
+		args := ArgsFiles{Pattern: "*.go"}
+		options := args.Predict(dir + "/")
+		assert.Contains(t, options, b)
+		assert.NotContains(t, options, a)
+
+		options = (&ArgsFiles{}).Predict(dir + "/")
+		assert.Contains(t, options, a)
+		assert.Contains(t, options, b)
+	})
+}
+
+func TestArgsDirs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	require.NoError(t, ioutil.WriteFile(file, nil, 0644))
+
+	var args ArgsDirs
+	require.NoError(t, args.Set([]string{dir, sub}))
+	assert.Equal(t, []string{dir, sub}, args.Paths)
+
+	err := args.Set([]string{dir, file})
+	assert.EqualError(t, err, "invalid directory positional argument at position 1 with value "+file+": not a directory")
+	assert.Error(t, args.Set([]string{filepath.Join(dir, "nope")}))
+
+	args = ArgsDirs{Pattern: "s*"}
+	assert.NoError(t, args.Set([]string{sub}))
+	assert.Error(t, args.Set([]string{dir}))
+
+	options := args.Predict(dir + "/")
+	assert.Contains(t, options, sub+"/")
+	assert.NotContains(t, options, file)
+}
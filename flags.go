@@ -0,0 +1,480 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/posener/complete/v2/predict"
+)
+
+// StringSlice defines a string slice flag. The flag can be repeated, and each value may hold
+// several comma separated values, such that `-tag a,b -tag c` results in `[a b c]`. Values that are
+// given in the command line replace the default value, and an empty value clears it. The check
+// option applies to each of the values.
+func (c *SubCmd) StringSlice(name string, value []string, usage string, options ...predict.Option) *[]string {
+	return defineFlag(c, name, value, usage, options, &flagValue[[]string]{
+		clear: true,
+		parse: func(v *[]string, s string) ([]string, error) {
+			if s == "" {
+				return nil, nil
+			}
+			values := strings.Split(s, ",")
+			*v = append(*v, values...)
+			return values, nil
+		},
+		format: func(v []string) string { return strings.Join(v, ",") },
+	})
+}
+
+// StringMap defines a key-value flag. The flag can be repeated, and each value should be of the
+// form `key=value`, such that `-label a=1 -label b=2` results in `map[a:1 b:2]`. Values that are
+// given in the command line replace the default value, and an empty value clears it. The check
+// option applies to the keys.
+func (c *SubCmd) StringMap(name string, value map[string]string, usage string, options ...predict.Option) *map[string]string {
+	return defineFlag(c, name, value, usage, options, &flagValue[map[string]string]{
+		clear: true,
+		parse: func(v *map[string]string, s string) ([]string, error) {
+			if s == "" {
+				return nil, nil
+			}
+			eq := strings.Index(s, "=")
+			if eq < 0 {
+				return nil, fmt.Errorf("expected key=value")
+			}
+			if *v == nil {
+				*v = make(map[string]string)
+			}
+			(*v)[s[:eq]] = s[eq+1:]
+			return []string{s[:eq]}, nil
+		},
+		format: func(v map[string]string) string {
+			var pairs []string
+			for key, value := range v {
+				pairs = append(pairs, key+"="+value)
+			}
+			sort.Strings(pairs)
+			return strings.Join(pairs, ",")
+		},
+	})
+}
+
+// Enum defines a flag that accepts one of the names of the given values. It is useful for flags
+// that are bound to Go constants, and completes the names of the values. It is a function since Go
+// methods can't have type parameters. For example:
+//
+// 	type level int
+//
+// 	const (
+// 		debug level = iota
+// 		info
+// 	)
+//
+// 	lvl := cmd.Enum(root.SubCmd, "level", info, map[string]level{"debug": debug, "info": info}, "log level")
+func Enum[T comparable](c *SubCmd, name string, value T, values map[string]T, usage string, options ...predict.Option) *T {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return defineFlag(c, name, value, usage, options, &flagValue[T]{
+		parse: func(v *T, s string) ([]string, error) {
+			value, ok := values[s]
+			if !ok {
+				return nil, fmt.Errorf("must be one of: %s", strings.Join(names, ", "))
+			}
+			*v = value
+			return []string{s}, nil
+		},
+		format: func(v T) string {
+			for _, name := range names {
+				if values[name] == v {
+					return name
+				}
+			}
+			return ""
+		},
+		names: predict.Set(names),
+	})
+}
+
+// Bytes defines a byte size flag. The value is a number followed by an optional unit: B, decimal
+// units such as KB and MB, or binary units such as KiB and MiB. Units are case insensitive, and a
+// unit without the B suffix is decimal. For example, `-size 10MiB` results in 10485760.
+func (c *SubCmd) Bytes(name string, value uint64, usage string, options ...predict.Option) *uint64 {
+	return defineFlag(c, name, value, usage, options, &flagValue[uint64]{
+		parse:  scalar(parseBytes),
+		format: formatBytes,
+	})
+}
+
+// Time defines a time flag. The value is parsed according to the layout, as in `time.Parse`. If
+// layout is empty, `time.RFC3339` is used. An empty value sets the zero time.
+func (c *SubCmd) Time(name string, value time.Time, layout string, usage string, options ...predict.Option) *time.Time {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return defineFlag(c, name, value, usage, options, &flagValue[time.Time]{
+		parse: scalar(func(s string) (time.Time, error) {
+			if s == "" {
+				return time.Time{}, nil
+			}
+			return time.Parse(layout, s)
+		}),
+		format: func(v time.Time) string {
+			if v.IsZero() {
+				return ""
+			}
+			return v.Format(layout)
+		},
+	})
+}
+
+// URL defines an absolute URL flag. An empty value sets a nil URL.
+func (c *SubCmd) URL(name string, value *url.URL, usage string, options ...predict.Option) **url.URL {
+	return defineFlag(c, name, value, usage, options, &flagValue[*url.URL]{
+		parse: scalar(func(s string) (*url.URL, error) {
+			if s == "" {
+				return nil, nil
+			}
+			u, err := url.Parse(s)
+			if err == nil && !u.IsAbs() {
+				err = fmt.Errorf("not an absolute URL")
+			}
+			return u, err
+		}),
+		format: func(v *url.URL) string {
+			if v == nil {
+				return ""
+			}
+			return v.String()
+		},
+	})
+}
+
+// IP defines an IP address flag. An empty value sets a nil IP.
+func (c *SubCmd) IP(name string, value net.IP, usage string, options ...predict.Option) *net.IP {
+	return defineFlag(c, name, value, usage, options, &flagValue[net.IP]{
+		parse: scalar(func(s string) (net.IP, error) {
+			if s == "" {
+				return nil, nil
+			}
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address")
+			}
+			return ip, nil
+		}),
+		format: func(v net.IP) string {
+			if v == nil {
+				return ""
+			}
+			return v.String()
+		},
+	})
+}
+
+// IPNet defines an IP network flag in CIDR notation, such as `10.0.0.0/8`. An empty value sets a
+// nil network.
+func (c *SubCmd) IPNet(name string, value *net.IPNet, usage string, options ...predict.Option) **net.IPNet {
+	return defineFlag(c, name, value, usage, options, &flagValue[*net.IPNet]{
+		parse: scalar(func(s string) (*net.IPNet, error) {
+			if s == "" {
+				return nil, nil
+			}
+			_, ipNet, err := net.ParseCIDR(s)
+			return ipNet, err
+		}),
+		format: func(v *net.IPNet) string {
+			if v == nil {
+				return ""
+			}
+			return v.String()
+		},
+	})
+}
+
+// Regexp defines a regular expression flag. An empty value sets a nil regular expression.
+func (c *SubCmd) Regexp(name string, value *regexp.Regexp, usage string, options ...predict.Option) **regexp.Regexp {
+	return defineFlag(c, name, value, usage, options, &flagValue[*regexp.Regexp]{
+		parse: scalar(func(s string) (*regexp.Regexp, error) {
+			if s == "" {
+				return nil, nil
+			}
+			return regexp.Compile(s)
+		}),
+		format: func(v *regexp.Regexp) string {
+			if v == nil {
+				return ""
+			}
+			return v.String()
+		},
+	})
+}
+
+// Count defines a counter flag, which is incremented each time it is given without a value, such
+// that `-v -v -v` results in 3. A value sets the counter, as in `-v=2`.
+func (c *SubCmd) Count(name string, value int, usage string, options ...predict.Option) *int {
+	return defineFlag(c, name, value, usage, options, &flagValue[int]{
+		boolFlag: true,
+		parse: func(v *int, s string) ([]string, error) {
+			if s == "true" {
+				*v++
+				return nil, nil
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad value for count flag")
+			}
+			*v = n
+			return []string{s}, nil
+		},
+		format: strconv.Itoa,
+	})
+}
+
+// NegatableBool defines a bool flag that can also be turned off with the `-no-` prefix, such that
+// `-color` sets true and `-no-color` sets false. It is useful for flags that are true by default.
+// Both forms are shown in the usage and completed, and the flag is reported by `IsSet` when either
+// of them is given.
+func (c *SubCmd) NegatableBool(name string, value bool, usage string, options ...predict.Option) *bool {
+	v := &flagValue[bool]{
+		boolFlag: true,
+		parse: func(v *bool, s string) ([]string, error) {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("bad value for bool flag")
+			}
+			*v = b
+			return []string{s}, nil
+		},
+		format: strconv.FormatBool,
+	}
+	p := defineFlag(c, name, value, usage, options, v)
+	(*flag.FlagSet)(c.FlagSet).Var(&negatedValue{v}, "no-"+name, "negates -"+name)
+	return p
+}
+
+// IsSet returns true if the flag with the given name was given in the last parsed command line, in
+// the command or in one of its sub commands.
+func (c *SubCmd) IsSet(name string) bool {
+	c.syncFlags()
+	f := c.Lookup(name)
+	if f == nil {
+		return false
+	}
+	if s, ok := f.Value.(interface{ isSet() bool }); ok {
+		return s.isSet()
+	}
+	return c.visitedFlag(f.Value)
+}
+
+// visitedFlag returns true if a flag with the given value was set in the command or in one of its
+// visited sub commands.
+func (c *SubCmd) visitedFlag(value flag.Value) bool {
+	set := false
+	c.Visit(func(f *flag.Flag) {
+		if f.Value == value {
+			set = true
+		}
+	})
+	for _, sub := range c.sub {
+		if !set && sub.visited {
+			set = sub.visitedFlag(value)
+		}
+	}
+	return set
+}
+
+// defineFlag defines a flag in the command with the given value type, and returns a pointer to the
+// flag variable.
+func defineFlag[T any](c *SubCmd, name string, value T, usage string, options []predict.Option, v *flagValue[T]) *T {
+	v.v = new(T)
+	*v.v = value
+	v.initial = value
+	v.Config = predict.Options(options...)
+	(*flag.FlagSet)(c.FlagSet).Var(v, name, usage)
+	return v.v
+}
+
+// flagValue is a flag value of type T. It implements the `flag.Getter` and the
+// `complete.Predictor` interfaces.
+type flagValue[T any] struct {
+	v       *T
+	initial T
+	// parse sets the value from a flag argument, and returns the values to check.
+	parse  func(v *T, s string) ([]string, error)
+	format func(v T) string
+	// clear sets the zero value before the first set, such that values in the command line replace
+	// the default value instead of being added to it.
+	clear bool
+	set   bool
+	// names are the completed values when the options don't define a predictor.
+	names    predict.Set
+	boolFlag bool
+	predict.Config
+}
+
+// Set implements the flag.Value interface.
+func (f *flagValue[T]) Set(s string) error {
+	if f.clear && !f.set {
+		var zero T
+		*f.v = zero
+	}
+	f.set = true
+	values, err := f.parse(f.v, s)
+	if err != nil {
+		return err
+	}
+	return f.check(values)
+}
+
+// Check checks a flag argument with the predict options. Arguments that can't be parsed pass the
+// check, since they fail the parsing.
+func (f *flagValue[T]) Check(s string) error {
+	var v T
+	values, err := f.parse(&v, s)
+	if err != nil {
+		return nil
+	}
+	return f.check(values)
+}
+
+// check checks the values of a flag argument with the predict options.
+func (f *flagValue[T]) check(values []string) error {
+	for _, value := range values {
+		if err := f.Config.Check(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *flagValue[T]) String() string {
+	if f == nil || f.v == nil || f.format == nil {
+		return ""
+	}
+	return f.format(*f.v)
+}
+
+// Get implements the flag.Getter interface.
+func (f *flagValue[T]) Get() interface{} { return *f.v }
+
+// IsBoolFlag implements the boolean flag interface of the flag package.
+func (f *flagValue[T]) IsBoolFlag() bool { return f.boolFlag }
+
+// Predict implements the complete.Predictor interface.
+func (f *flagValue[T]) Predict(prefix string) []string {
+	switch {
+	case f.Predictor != nil:
+		return f.Predictor.Predict(prefix)
+	case f.names != nil:
+		return f.names.Predict(prefix)
+	case f.boolFlag:
+		// Nothing to complete after a flag that does not take a value.
+		return nil
+	}
+	return []string{""}
+}
+
+// isSet returns true if the value was set since the last reset.
+func (f *flagValue[T]) isSet() bool { return f.set }
+
+// reset sets the value to its value at definition time.
+func (f *flagValue[T]) reset() {
+	*f.v = f.initial
+	f.set = false
+}
+
+// scalar returns a parse function of a flagValue from a function that parses a single value.
+func scalar[T any](parse func(string) (T, error)) func(*T, string) ([]string, error) {
+	return func(v *T, s string) ([]string, error) {
+		value, err := parse(s)
+		if err != nil {
+			return nil, err
+		}
+		*v = value
+		return []string{s}, nil
+	}
+}
+
+var byteUnits = []struct {
+	name string
+	size uint64
+}{
+	{"PiB", 1 << 50}, {"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"PB", 1e15}, {"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"P", 1e15}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6}, {"K", 1e3},
+	{"B", 1},
+}
+
+// parseBytes parses a byte size, such as 10MiB.
+func parseBytes(s string) (uint64, error) {
+	number, size := strings.TrimSpace(s), uint64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(number), strings.ToUpper(unit.name)) {
+			number, size = strings.TrimSpace(number[:len(number)-len(unit.name)]), unit.size
+			break
+		}
+	}
+	// Integers are parsed exactly, and only fractions are parsed as floats.
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/size {
+			return 0, fmt.Errorf("invalid byte size %q", s)
+		}
+		return n * size, nil
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || n < 0 || n*float64(size) >= 1<<64 {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return uint64(n * float64(size)), nil
+}
+
+// formatBytes formats a byte size with the largest binary unit that divides it.
+func formatBytes(v uint64) string {
+	for _, unit := range byteUnits[:5] {
+		if v != 0 && v%unit.size == 0 {
+			return strconv.FormatUint(v/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatUint(v, 10) + "B"
+}
+
+// negatedValue is the value of the `-no-` form of a negatable bool flag, which sets the negation of
+// its argument to the bool flag value.
+type negatedValue struct {
+	v *flagValue[bool]
+}
+
+func (n *negatedValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("bad value for bool flag")
+	}
+	return n.v.Set(strconv.FormatBool(!b))
+}
+
+// String returns an empty string, such that no default value is shown in the usage.
+func (n *negatedValue) String() string { return "" }
+
+// Get implements the flag.Getter interface.
+func (n *negatedValue) Get() interface{} { return !n.v.Get().(bool) }
+
+// IsBoolFlag implements the boolean flag interface of the flag package.
+func (n *negatedValue) IsBoolFlag() bool { return true }
+
+// Predict implements the complete.Predictor interface. Nothing is completed after the flag.
+func (n *negatedValue) Predict(string) []string { return nil }
+
+func (n *negatedValue) isSet() bool { return n.v.isSet() }
+
+// reset resets the bool flag value, since only the flags that were given are reset.
+func (n *negatedValue) reset() { n.v.reset() }
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/posener/complete/v2/predict"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlags(t *testing.T) {
+	t.Parallel()
+
+	type level int
+
+	var (
+		root  = New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		tags  = root.StringSlice("tag", []string{"default"}, "", predict.OptValues("a", "b", "c", "default"), predict.OptCheck())
+		label = root.StringMap("label", nil, "")
+		lvl   = Enum(root.SubCmd, "level", level(1), map[string]level{"debug": 0, "info": 1, "warn": 2}, "")
+		size  = root.Bytes("size", 1<<10, "")
+		since = root.Time("since", time.Time{}, "2006-01-02", "")
+		addr  = root.URL("url", nil, "")
+		ip    = root.IP("ip", nil, "")
+		ipNet = root.IPNet("net", nil, "")
+		re    = root.Regexp("re", regexp.MustCompile("^a"), "")
+		v     = root.Count("v", 0, "")
+		sub   = root.SubCommand("sub", "")
+	)
+	sub.ArgsVar(&ArgsStr{}, "", "")
+
+	t.Run("defaults", func(t *testing.T) {
+		require.NoError(t, root.ParseArgs("cmd", "sub"))
+		assert.Equal(t, []string{"default"}, *tags)
+		assert.Nil(t, *label)
+		assert.Equal(t, level(1), *lvl)
+		assert.Equal(t, uint64(1024), *size)
+		assert.True(t, since.IsZero())
+		assert.Nil(t, *addr)
+		assert.Nil(t, *ip)
+		assert.Nil(t, *ipNet)
+		assert.Equal(t, "^a", (*re).String())
+		assert.Equal(t, 0, *v)
+	})
+
+	t.Run("set in sub command", func(t *testing.T) {
+		require.NoError(t, root.ParseArgs("cmd", "sub",
+			"-tag", "a,b", "-tag", "c",
+			"-label", "x=1", "-label", "y=a=b",
+			"-level", "warn",
+			"-size", "10MiB",
+			"-since", "2020-01-02",
+			"-url", "https://example.com/path",
+			"-ip", "10.0.0.1",
+			"-net", "10.0.0.0/8",
+			"-re", "b+",
+			"-v", "-v", "-v",
+		))
+		assert.Equal(t, []string{"a", "b", "c"}, *tags)
+		assert.Equal(t, map[string]string{"x": "1", "y": "a=b"}, *label)
+		assert.Equal(t, level(2), *lvl)
+		assert.Equal(t, uint64(10<<20), *size)
+		assert.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), *since)
+		assert.Equal(t, "https://example.com/path", (*addr).String())
+		assert.Equal(t, net.ParseIP("10.0.0.1"), *ip)
+		assert.Equal(t, "10.0.0.0/8", (*ipNet).String())
+		assert.Equal(t, "b+", (*re).String())
+		assert.Equal(t, 3, *v)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		require.NoError(t, root.ParseArgs("cmd", "sub", "-tag", "b", "-label", "x=1", "-v"))
+		assert.Equal(t, []string{"b"}, *tags)
+		assert.Equal(t, map[string]string{"x": "1"}, *label)
+		assert.Equal(t, 1, *v)
+		root.Reset()
+		assert.Equal(t, []string{"default"}, *tags)
+		assert.Nil(t, *label)
+		assert.Equal(t, 0, *v)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, arg := range []string{
+			"-label=x",
+			"-level=error",
+			"-size=10XB",
+			"-size=-1",
+			"-size=NaN",
+			"-size=Inf",
+			"-size=18446744073709551616",
+			"-size=16384PiB",
+			"-size=16384.0PiB",
+			"-since=2020",
+			"-url=/path",
+			"-ip=10.0.0",
+			"-net=10.0.0.1",
+			"-re=(",
+			"-v=x",
+		} {
+			err := root.ParseArgs("cmd", "sub", arg)
+			var e *FlagError
+			assert.True(t, errors.As(err, &e), "%s: %v", arg, err)
+			assert.False(t, errors.As(err, new(*CheckError)), "%s: %v", arg, err)
+		}
+	})
+
+	t.Run("byte size limits", func(t *testing.T) {
+		require.NoError(t, root.ParseArgs("cmd", "sub", "-size", "18446744073709551615"))
+		assert.Equal(t, uint64(math.MaxUint64), *size)
+		require.NoError(t, root.ParseArgs("cmd", "sub", "-size", "16383PiB"))
+		assert.Equal(t, uint64(16383<<50), *size)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		err := root.ParseArgs("cmd", "sub", "-tag", "a,d")
+		var e *CheckError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "tag", e.Flag)
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		c := (*completer)(sub)
+		assert.Equal(t, []string{"debug", "info", "warn"}, c.FlagGet("level").Predict(""))
+		assert.Equal(t, []string{"a", "b", "c", "default"}, c.FlagGet("tag").Predict(""))
+		assert.Nil(t, c.FlagGet("v").Predict(""))
+		assert.Equal(t, []string{""}, c.FlagGet("size").Predict(""))
+	})
+
+	t.Run("random args", func(t *testing.T) {
+		r := rand.New(rand.NewSource(0))
+		for i := 0; i < 100; i++ {
+			args := root.RandomArgs(r)
+			assert.NoError(t, root.ParseArgs(args...), "args: %v", args)
+		}
+	})
+}
+
+func TestParseBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  uint64
+	}{
+		{value: "0", want: 0},
+		{value: "10", want: 10},
+		{value: "10B", want: 10},
+		{value: "1KB", want: 1000},
+		{value: "1k", want: 1000},
+		{value: "1KiB", want: 1024},
+		{value: "10MiB", want: 10 << 20},
+		{value: "1.5 GiB", want: 3 << 29},
+		{value: "2TB", want: 2e12},
+		{value: "1PiB", want: 1 << 50},
+		{value: "18446744073709551615", want: math.MaxUint64},
+		{value: "9007199254740993", want: 1<<53 + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseBytes(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			// Formatting and parsing again results in the same value.
+			got, err = parseBytes(formatBytes(got))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, "10MiB", formatBytes(10<<20))
+	assert.Equal(t, "1000B", formatBytes(1000))
+	assert.Equal(t, "0B", formatBytes(0))
+}
+
+func TestNegatableBool(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	root := New(OptName("cmd"), OptOutput(&out), OptErrorHandling(flag.ContinueOnError))
+	color := root.NegatableBool("color", true, "colorize output")
+	dry := root.Bool("dry", false, "")
+	sub := root.SubCommand("sub", "")
+	sub.ArgsVar(&ArgsStr{}, "", "")
+
+	tests := []struct {
+		args    []string
+		want    bool
+		wantSet bool
+	}{
+		{args: []string{"cmd", "sub"}, want: true},
+		{args: []string{"cmd", "sub", "-color"}, want: true, wantSet: true},
+		{args: []string{"cmd", "sub", "-no-color"}, want: false, wantSet: true},
+		{args: []string{"cmd", "sub", "--no-color"}, want: false, wantSet: true},
+		{args: []string{"cmd", "sub", "-no-color=false"}, want: true, wantSet: true},
+		{args: []string{"cmd", "sub", "-color=false"}, want: false, wantSet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			require.NoError(t, root.ParseArgs(tt.args...))
+			assert.Equal(t, tt.want, *color)
+			assert.Equal(t, tt.wantSet, root.IsSet("color"))
+			assert.Equal(t, tt.wantSet, sub.IsSet("color"))
+		})
+	}
+
+	t.Run("standard flag", func(t *testing.T) {
+		require.NoError(t, root.ParseArgs("cmd", "sub", "-dry"))
+		assert.True(t, *dry)
+		assert.True(t, root.IsSet("dry"))
+		assert.True(t, sub.IsSet("dry"))
+		assert.False(t, root.IsSet("color"))
+		assert.False(t, root.IsSet("nope"))
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		out.Reset()
+		sub.Usage()
+		assert.Contains(t, out.String(), "  -color\n    \tcolorize output (default true)\n")
+		assert.Contains(t, out.String(), "  -no-color\n    \tnegates -color\n")
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		c := (*completer)(sub)
+		assert.Subset(t, c.FlagList(), []string{"color", "no-color"})
+		assert.Nil(t, c.FlagGet("no-color").Predict(""))
+	})
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GlobNoMatch defines how `ArgsGlob` handles patterns that match no files.
+type GlobNoMatch int
+
+const (
+	// GlobNoMatchError fails the parsing of the arguments.
+	GlobNoMatchError GlobNoMatch = iota
+	// GlobNoMatchKeep keeps the pattern as an argument, as a shell does.
+	GlobNoMatchKeep
+	// GlobNoMatchRemove removes the pattern from the arguments.
+	GlobNoMatchRemove
+)
+
+// ArgsGlob returns positional arguments that are parsed by the given value, after the patterns in
+// the arguments are expanded to the matching file paths, relative to the working directory. It is
+// useful when the program is not invoked by a shell, which expands the patterns. The patterns are
+// as in `filepath.Match`, and the `**` path element matches any number of directories. The matches
+// of each pattern are sorted. Arguments without pattern characters are not changed. The noMatch
+// argument defines how patterns that match no files are handled. For example:
+//
+// 	var files cmd.ArgsFiles
+// 	root.ArgsVar(cmd.ArgsGlob(&files, cmd.GlobNoMatchError), "[file...]", "files or patterns")
+func ArgsGlob(value ArgsValue, noMatch GlobNoMatch) ArgsValue {
+	return &argsGlob{value: value, noMatch: noMatch, initial: snapshot(value)}
+}
+
+// argsGlob is a positional arguments value that expands patterns in the arguments.
+type argsGlob struct {
+	value   ArgsValue
+	noMatch GlobNoMatch
+	initial reflect.Value
+}
+
+// Set implements the ArgsValue interface.
+func (a *argsGlob) Set(args []string) error {
+	return setExpanded(a.value, args, func(arg string) ([]string, error) {
+		if !hasMeta(arg) {
+			return []string{arg}, nil
+		}
+		matches, err := glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: %v", arg, err)
+		}
+		if len(matches) > 0 {
+			return matches, nil
+		}
+		switch a.noMatch {
+		case GlobNoMatchKeep:
+			return []string{arg}, nil
+		case GlobNoMatchRemove:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("no files match pattern %s", arg)
+		}
+	})
+}
+
+func (a *argsGlob) reset() { resetWrapped(a.value, a.initial) }
+
+func (a *argsGlob) unwrap() ArgsValue { return a.value }
+
+// hasMeta returns true if the path contains any of the pattern characters.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
+
+// glob returns the sorted file paths that match a pattern. It extends `filepath.Glob` with the
+// `**` path element, which matches any number of directories.
+func glob(pattern string) ([]string, error) {
+	elems := strings.Split(filepath.Clean(pattern), string(filepath.Separator))
+	recursive := false
+	for _, elem := range elems {
+		if elem == "**" {
+			recursive = true
+		}
+		// Check that the pattern is valid, since the walk below does not report it.
+		if _, err := filepath.Match(elem, ""); err != nil {
+			return nil, err
+		}
+	}
+	if !recursive {
+		return filepath.Glob(pattern)
+	}
+
+	// Walk from the longest prefix of the pattern that has no pattern characters.
+	i := 0
+	for i < len(elems) && !hasMeta(elems[i]) {
+		i++
+	}
+	root := strings.Join(elems[:i], string(filepath.Separator))
+	switch {
+	case i == 0:
+		root = "."
+	case root == "":
+		root = string(filepath.Separator)
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories, as `filepath.Glob` does.
+			return nil
+		}
+		if i == 0 && path == root {
+			return nil
+		}
+		if matchElems(elems, strings.Split(path, string(filepath.Separator))) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchElems returns true if the path elements match the pattern elements.
+func matchElems(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		return matchElems(pattern[1:], path) || (len(path) > 0 && matchElems(pattern, path[1:]))
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchElems(pattern[1:], path[1:])
+}
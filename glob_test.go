@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgsGlob(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := func(elems ...string) string {
+		return filepath.Join(append([]string{dir}, elems...)...)
+	}
+	require.NoError(t, os.MkdirAll(path("sub", "deep"), 0755))
+	for _, name := range []string{"a.go", "b.go", "c.txt", path("sub", "d.go"), path("sub", "deep", "e.go")} {
+		if !filepath.IsAbs(name) {
+			name = path(name)
+		}
+		require.NoError(t, ioutil.WriteFile(name, nil, 0644))
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		noMatch GlobNoMatch
+		want    ArgsStr
+	}{
+		{
+			name: "star",
+			args: []string{path("*.go")},
+			want: ArgsStr{path("a.go"), path("b.go")},
+		},
+		{
+			name: "question mark and class",
+			args: []string{path("?.txt"), path("[ab].go")},
+			want: ArgsStr{path("c.txt"), path("a.go"), path("b.go")},
+		},
+		{
+			name: "recursive",
+			args: []string{path("**", "*.go")},
+			want: ArgsStr{path("a.go"), path("b.go"), path("sub", "d.go"), path("sub", "deep", "e.go")},
+		},
+		{
+			name: "recursive in the middle",
+			args: []string{path("sub", "**", "e.go")},
+			want: ArgsStr{path("sub", "deep", "e.go")},
+		},
+		{
+			name: "no pattern",
+			args: []string{"plain", path("*.txt")},
+			want: ArgsStr{"plain", path("c.txt")},
+		},
+		{
+			name:    "no match keep",
+			args:    []string{path("*.md"), "plain"},
+			noMatch: GlobNoMatchKeep,
+			want:    ArgsStr{path("*.md"), "plain"},
+		},
+		{
+			name:    "no match remove",
+			args:    []string{path("*.md"), "plain"},
+			noMatch: GlobNoMatchRemove,
+			want:    ArgsStr{"plain"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ArgsStr
+			require.NoError(t, ArgsGlob(&got, tt.noMatch).Set(tt.args))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("no match error", func(t *testing.T) {
+		var got ArgsStr
+		err := ArgsGlob(&got, GlobNoMatchError).Set([]string{"plain", path("*.md")})
+		var argErr *ArgError
+		require.True(t, errors.As(err, &argErr))
+		assert.Equal(t, 1, argErr.Index)
+		assert.EqualError(t, err, "no files match pattern "+path("*.md"))
+	})
+
+	t.Run("bad pattern", func(t *testing.T) {
+		var got ArgsStr
+		err := ArgsGlob(&got, GlobNoMatchError).Set([]string{path("**", "[")})
+		assert.Error(t, err)
+	})
+
+	t.Run("error position", func(t *testing.T) {
+		got := make(ArgsInt, 0)
+		err := ArgsGlob(&got, GlobNoMatchKeep).Set([]string{"1", path("*.go"), "x"})
+		var argErr *ArgError
+		require.True(t, errors.As(err, &argErr))
+		assert.Equal(t, 1, argErr.Index)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		root := New(OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		var got ArgsStr
+		root.ArgsVar(ArgsGlob(&got, GlobNoMatchError), "[file...]", "")
+		require.NoError(t, root.ParseArgs("cmd", path("*.txt")))
+		assert.Equal(t, ArgsStr{path("c.txt")}, got)
+		assert.Error(t, root.ParseArgs("cmd", path("*.md")))
+	})
+}
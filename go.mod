@@ -1,9 +1,20 @@
 module github.com/posener/cmd
 
-go 1.13
+go 1.18
 
 require (
 	github.com/posener/complete/v2 v2.0.1-alpha.12
 	github.com/posener/formatter v1.0.0
 	github.com/stretchr/testify v1.4.0
+	golang.org/x/term v0.5.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/posener/script v1.0.4 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
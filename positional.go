@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/posener/complete/v2"
+	"github.com/posener/complete/v2/predict"
+)
+
+// Arg defines a required named positional argument of the command. Named positional arguments are
+// parsed in the order of definition, and the usage string of the positional arguments is generated
+// from their names. The value should be a pointer to a string, int, float64, bool or
+// time.Duration, or a `flag.Value`. The options define the completion and the check of this
+// argument. For example, a copy command:
+//
+// 	var src, dst string
+// 	root.Arg("src", &src, "source file", predict.OptPredictor(predict.Files("*")))
+// 	root.Arg("dst", &dst, "destination file", predict.OptPredictor(predict.Files("*")))
+//
+// Named positional arguments can't be used together with `Args` or `ArgsVar` in the same command.
+func (c *SubCmd) Arg(name string, value interface{}, usage string, options ...predict.Option) {
+	c.addArg(newNamedArg(name, argValue(name, value), value, usage, argRequired, options))
+}
+
+// ArgOptional defines an optional named positional argument of the command, as in `Arg`. Optional
+// arguments must be defined after the required arguments. The value is not changed when the
+// argument is not given.
+func (c *SubCmd) ArgOptional(name string, value interface{}, usage string, options ...predict.Option) {
+	c.addArg(newNamedArg(name, argValue(name, value), value, usage, argOptional, options))
+}
+
+// ArgVariadic defines a named positional argument of the command that collects all the remaining
+// arguments, as in `Arg`. It must be the last defined argument. The value should be a pointer to a
+// slice of one of the types that `Arg` supports, or a `flag.Value`, which is set with each of the
+// remaining arguments. For example:
+//
+// 	var files []string
+// 	root.ArgVariadic("files", &files, "files to process")
+func (c *SubCmd) ArgVariadic(name string, value interface{}, usage string, options ...predict.Option) {
+	c.addArg(newNamedArg(name, variadicValue(name, value), value, usage, argVariadic, options))
+}
+
+func (c *SubCmd) addArg(arg *namedArg) {
+	if c.args == nil {
+		c.ArgsVar(&namedArgs{}, "", "")
+	}
+	args, ok := c.args.value.(*namedArgs)
+	if !ok {
+		c.addProblem("named positional argument %s of %s can't be used with Args() or ArgsVar()", arg.name, c.name)
+		return
+	}
+	if n := len(args.args); n > 0 {
+		last := args.args[n-1]
+		switch {
+		case last.kind == argVariadic:
+			c.addProblem("positional argument %s of %s is defined after variadic argument %s", arg.name, c.name, last.name)
+			return
+		case last.kind == argOptional && arg.kind == argRequired:
+			c.addProblem("required positional argument %s of %s is defined after optional argument %s", arg.name, c.name, last.name)
+			return
+		}
+	}
+	args.args = append(args.args, arg)
+	c.args.usage = args.usage()
+	c.args.details = args.details()
+}
+
+type argKind int
+
+const (
+	argRequired argKind = iota
+	argOptional
+	argVariadic
+)
+
+// namedArg is a positional argument that was defined with `Arg`, `ArgOptional` or `ArgVariadic`.
+type namedArg struct {
+	name    string
+	usage   string
+	kind    argKind
+	value   flag.Value
+	predict predict.Config
+	// target is the value given in the definition, and initial is its value at definition time.
+	target  interface{}
+	initial reflect.Value
+}
+
+func newNamedArg(name string, value flag.Value, target interface{}, usage string, kind argKind, options []predict.Option) *namedArg {
+	return &namedArg{
+		name:    name,
+		usage:   usage,
+		kind:    kind,
+		value:   value,
+		predict: predict.Options(options...),
+		target:  target,
+		initial: snapshot(target),
+	}
+}
+
+// namedArgs is an `ArgsValue` of the named positional arguments of a command.
+type namedArgs struct {
+	args []*namedArg
+}
+
+// Set implements the ArgsValue interface.
+func (a *namedArgs) Set(args []string) error {
+	required := 0
+	for _, arg := range a.args {
+		if arg.kind == argRequired {
+			required++
+		}
+	}
+	if len(args) < required {
+		return fmt.Errorf("missing positional argument %s", a.args[len(args)].name)
+	}
+	for i, value := range args {
+		arg := a.at(i)
+		if arg == nil {
+			return &ArgError{Index: i, Err: fmt.Errorf("unexpected positional argument %q", value)}
+		}
+		if v, ok := arg.value.(*sliceValue); ok && i == len(a.args)-1 {
+			v.clear()
+		}
+		if err := arg.value.Set(value); err != nil {
+			return &ArgError{Index: i, Err: fmt.Errorf("invalid value %q for argument %s: %v", value, arg.name, err)}
+		}
+	}
+	return nil
+}
+
+// reset sets the values of the arguments to their value at definition time.
+func (a *namedArgs) reset() {
+	for _, arg := range a.args {
+		restore(arg.target, arg.initial)
+	}
+}
+
+// at returns the argument in the given position, or nil if there is no such argument.
+func (a *namedArgs) at(i int) *namedArg {
+	if i < len(a.args) {
+		return a.args[i]
+	}
+	if n := len(a.args); n > 0 && a.args[n-1].kind == argVariadic {
+		return a.args[n-1]
+	}
+	return nil
+}
+
+// config returns the prediction configuration of the argument in the given position.
+func (a *namedArgs) config(i int) predict.Config {
+	if arg := a.at(i); arg != nil {
+		return arg.predict
+	}
+	return predict.Config{}
+}
+
+// predictor returns the predictor of the argument in the given position, or nil if it has none.
+func (a *namedArgs) predictor(i int) complete.Predictor {
+	if arg := a.at(i); arg != nil && arg.predict.Predictor != nil {
+		return arg.predict
+	}
+	return nil
+}
+
+func (a *namedArgs) usage() string {
+	var parts []string
+	for _, arg := range a.args {
+		switch arg.kind {
+		case argRequired:
+			parts = append(parts, arg.name)
+		case argOptional:
+			parts = append(parts, "["+arg.name+"]")
+		case argVariadic:
+			parts = append(parts, "["+arg.name+"...]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (a *namedArgs) details() string {
+	length := 0
+	for _, arg := range a.args {
+		if len(arg.name) > length {
+			length = len(arg.name)
+		}
+	}
+	var lines []string
+	for _, arg := range a.args {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", length, arg.name, arg.usage))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// argValue returns a `flag.Value` that sets the given value, which should be a pointer to a
+// supported type or a `flag.Value`.
+func argValue(name string, value interface{}) flag.Value {
+	if v, ok := value.(flag.Value); ok {
+		return v
+	}
+	// Use the values of the flag package to parse the supported types.
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	switch v := value.(type) {
+	case *string:
+		fs.StringVar(v, name, *v, "")
+	case *int:
+		fs.IntVar(v, name, *v, "")
+	case *float64:
+		fs.Float64Var(v, name, *v, "")
+	case *bool:
+		fs.BoolVar(v, name, *v, "")
+	case *time.Duration:
+		fs.DurationVar(v, name, *v, "")
+	default:
+		panic(fmt.Sprintf("positional argument %s has unsupported type %T", name, value))
+	}
+	return fs.Lookup(name).Value
+}
+
+// variadicValue returns a `flag.Value` that appends values to the given value, which should be a
+// pointer to a slice of a supported type or a `flag.Value`.
+func variadicValue(name string, value interface{}) flag.Value {
+	if v, ok := value.(flag.Value); ok {
+		return v
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		panic(fmt.Sprintf("variadic positional argument %s has unsupported type %T", name, value))
+	}
+	slice := &sliceValue{name: name, slice: v.Elem()}
+	// Check that the element type is supported.
+	slice.newElem()
+	return slice
+}
+
+// sliceValue is a `flag.Value` that appends values to a slice.
+type sliceValue struct {
+	name  string
+	slice reflect.Value
+}
+
+func (s *sliceValue) String() string { return "" }
+
+func (s *sliceValue) Set(value string) error {
+	elem, v := s.newElem()
+	if err := v.Set(value); err != nil {
+		return err
+	}
+	s.slice.Set(reflect.Append(s.slice, elem.Elem()))
+	return nil
+}
+
+// Get implements the `flag.Getter` interface, and returns a value of the element type.
+func (s *sliceValue) Get() interface{} {
+	_, v := s.newElem()
+	return v.(flag.Getter).Get()
+}
+
+// clear sets the slice to a new empty slice, such that the slice given in the definition is not
+// modified.
+func (s *sliceValue) clear() {
+	s.slice.Set(reflect.MakeSlice(s.slice.Type(), 0, 0))
+}
+
+// newElem returns a pointer to a new slice element, and a value that sets it.
+func (s *sliceValue) newElem() (reflect.Value, flag.Value) {
+	elem := reflect.New(s.slice.Type().Elem())
+	return elem, argValue(s.name, elem.Interface())
+}
+
+// argIndex returns the index of the positional argument that follows the given arguments of the
+// command.
+func (c *SubCmd) argIndex(args []string) int {
+	c.syncFlags()
+	i := 0
+	for j := 0; j < len(args); j++ {
+		arg := args[j]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			i++
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		// A flag that is not a bool flag takes the next argument as its value.
+		if f := c.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				j++
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/posener/complete/v2/predict"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArg(t *testing.T) {
+	t.Parallel()
+
+	var (
+		out   bytes.Buffer
+		src   string
+		n     int
+		f     float64
+		b     bool
+		d     time.Duration
+		rest  []int
+		value ArgsStr
+	)
+
+	newRoot := func() *Cmd {
+		out.Reset()
+		src, n, f, b, d, rest = "", 0, 0, false, 0, nil
+		root := New(OptName("cmd"), OptOutput(&out), OptErrorHandling(flag.ContinueOnError))
+		root.Arg("src", &src, "source", predict.OptValues("a", "b"), predict.OptCheck())
+		root.Arg("n", &n, "number")
+		root.ArgOptional("f", &f, "float")
+		root.ArgOptional("b", &b, "bool")
+		root.ArgOptional("d", &d, "duration")
+		root.ArgVariadic("rest", &rest, "rest of numbers")
+		return root
+	}
+
+	t.Run("all", func(t *testing.T) {
+		root := newRoot()
+		require.NoError(t, root.ParseArgs("cmd", "a", "1", "1.5", "true", "1s", "2", "3"))
+		assert.Equal(t, "a", src)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1.5, f)
+		assert.Equal(t, true, b)
+		assert.Equal(t, time.Second, d)
+		assert.Equal(t, []int{2, 3}, rest)
+	})
+
+	t.Run("required only", func(t *testing.T) {
+		root := newRoot()
+		require.NoError(t, root.ParseArgs("cmd", "b", "2"))
+		assert.Equal(t, "b", src)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, 0.0, f)
+		assert.Empty(t, rest)
+	})
+
+	t.Run("reset between parses", func(t *testing.T) {
+		root := newRoot()
+		require.NoError(t, root.ParseArgs("cmd", "a", "1", "1.5", "true", "1s", "2", "3"))
+		require.NoError(t, root.ParseArgs("cmd", "b", "2"))
+		assert.Equal(t, 0.0, f)
+		assert.Equal(t, false, b)
+		assert.Empty(t, rest)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		root := newRoot()
+		err := root.ParseArgs("cmd", "a")
+		assert.EqualError(t, err, "cmd: bad positional args: missing positional argument n")
+	})
+
+	t.Run("bad value", func(t *testing.T) {
+		root := newRoot()
+		err := root.ParseArgs("cmd", "a", "1", "x")
+		var e *ArgsError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "x", e.Arg)
+		assert.EqualError(t, err, `cmd: bad positional args: invalid value "x" for argument f: parse error`)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		root := newRoot()
+		err := root.ParseArgs("cmd", "c", "1")
+		var e *CheckError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "c", e.Arg)
+		assert.Equal(t, []string{"a", "b"}, e.Suggestions)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		root := newRoot()
+		assert.True(t, errors.Is(root.ParseArgs("cmd", "-h"), flag.ErrHelp))
+		assert.Contains(t, out.String(), "Usage: cmd src n [f] [b] [d] [rest...]\n")
+		assert.Contains(t, out.String(), "src   source\n")
+		assert.Contains(t, out.String(), "rest  rest of numbers\n")
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		root := New(OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))
+		root.Arg("src", &src, "")
+		err := root.ParseArgs("cmd", "a", "b")
+		var e *ArgsError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "b", e.Arg)
+	})
+
+	t.Run("invalid definitions", func(t *testing.T) {
+		root := New(OptName("cmd"))
+		root.ArgOptional("a", &src, "")
+		root.Arg("b", &src, "")
+		root.ArgVariadic("c", &rest, "")
+		root.ArgOptional("d", &src, "")
+		assert.EqualError(t, root.Validate(), "required positional argument b of cmd is defined after optional argument a\n"+
+			"positional argument d of cmd is defined after variadic argument c")
+		assert.Panics(t, func() { root.ParseArgs("cmd") })
+
+		root = New()
+		assert.Panics(t, func() { root.Arg("a", new(int64), "") }, "unsupported type")
+		assert.Panics(t, func() { root.ArgVariadic("a", &src, "") }, "variadic of non slice")
+
+		root = New(OptName("cmd"))
+		root.ArgsVar(&value, "", "")
+		root.Arg("a", &src, "")
+		assert.EqualError(t, root.Validate(), "named positional argument a of cmd can't be used with Args() or ArgsVar()")
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		root := New(OptName("cmd"))
+		root.Int("flag", 0, "")
+		root.Bool("bool", false, "")
+		root.Arg("src", &src, "", predict.OptValues("src1", "src2"))
+		root.Arg("n", &n, "")
+		root.ArgVariadic("rest", &rest, "", predict.OptValues("10", "20"))
+
+		assert.Equal(t, []string{"src1", "src2"}, root.completeLine(""))
+		assert.Equal(t, []string{"src1", "src2"}, root.completeLine("-flag 1 -bool "))
+		assert.Nil(t, root.completeLine("src1 "))
+		assert.Equal(t, []string{"10", "20"}, root.completeLine("src1 -flag=1 1 "))
+		assert.Equal(t, []string{"20"}, root.completeLine("src1 1 10 2"))
+	})
+
+	t.Run("random", func(t *testing.T) {
+		root := newRoot()
+		r := rand.New(rand.NewSource(0))
+		for i := 0; i < 100; i++ {
+			args := root.RandomArgs(r)
+			assert.NoError(t, root.ParseArgs(args...), "args: %v", args)
+		}
+	})
+}
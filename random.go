@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"flag"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/posener/complete/v2"
+)
+
+// maxRandomArgs is the maximal number of positional arguments that are tried when generating a
+// random command line.
+const maxRandomArgs = 8
+
+// RandomArgs returns a random valid command line for the command tree. The first argument is the
+// command name, and the result can be passed directly to `ParseArgs`. It chooses a random path of
+// sub commands, sets a random subset of the flags and adds positional arguments that are accepted
+// by the command. The path may end at a command that has both sub commands and positional
+// arguments.
+//
+// Flag values and positional arguments are chosen from the values that were defined with the
+// `predict` options. Otherwise, values are generated according to the flag type. Positional
+// arguments that have no predicted values are found by trying random candidates with the
+// `ArgsValue.Set` method, which means that it is called during the generation. Positional
+// arguments variables are reset afterwards, as in the `Reset` method. Positional arguments that
+// are defined with `ArgsStdin` are always given in the command line, such that the standard input
+// is not read.
+//
+// It can be used for property based testing, for example with the `testing/quick` package:
+//
+// 	func TestParse(t *testing.T) {
+// 		f := func(seed int64) bool {
+// 			args := newRoot().RandomArgs(rand.New(rand.NewSource(seed)))
+// 			return newRoot().ParseArgs(args...) == nil
+// 		}
+// 		if err := quick.Check(f, nil); err != nil {
+// 			t.Error(err)
+// 		}
+// 	}
+func (c *Cmd) RandomArgs(r *rand.Rand) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.SubCmd.randomArgs(r, []string{c.name})
+}
+
+func (c *SubCmd) randomArgs(r *rand.Rand, args []string) []string {
+	if len(c.sub) > 0 {
+		names := c.subNames()
+		// A command with positional arguments is sometimes invoked itself, unless its arguments
+		// would be parsed as a sub command.
+		if c.args != nil && c.defaultSub() == "" && r.Intn(len(names)+1) == 0 {
+			if own := c.randomOwnArgs(r); len(own) == 0 || c.subCmd(own[0]) == nil {
+				return append(args, own...)
+			}
+		}
+		name := names[r.Intn(len(names))]
+		return c.subCmd(name).randomArgs(r, append(args, name))
+	}
+	return append(args, c.randomOwnArgs(r)...)
+}
+
+// randomOwnArgs returns random flags and positional arguments of the command.
+func (c *SubCmd) randomOwnArgs(r *rand.Rand) []string {
+	var args []string
+	c.syncFlags()
+	c.VisitAll(func(f *flag.Flag) {
+		if r.Intn(2) == 0 {
+			return
+		}
+		args = append(args, "-"+f.Name+"="+randomFlagValue(r, f))
+	})
+	return append(args, c.randomPositional(r)...)
+}
+
+// randomFlagValue returns a random valid value for a given flag.
+func randomFlagValue(r *rand.Rand, f *flag.Flag) string {
+	if values := predictedValues(f.Value, f.Value); len(values) > 0 {
+		return values[r.Intn(len(values))]
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return f.DefValue
+	}
+	switch getter.Get().(type) {
+	case bool:
+		return strconv.FormatBool(r.Intn(2) == 0)
+	case int:
+		return randomInt(r)
+	case float64:
+		return strconv.FormatFloat(r.Float64()*100, 'f', 2, 64)
+	case time.Duration:
+		return (time.Duration(r.Intn(1000)) * time.Millisecond).String()
+	case string:
+		return randomWord(r)
+	default:
+		return f.DefValue
+	}
+}
+
+// randomPositional returns random positional arguments that are accepted by the command.
+func (c *SubCmd) randomPositional(r *rand.Rand) []string {
+	if c.args == nil {
+		return nil
+	}
+
+	// Named positional arguments are generated according to their definition.
+	if named, ok := c.args.value.(*namedArgs); ok {
+		for try := 0; try < maxRandomArgs; try++ {
+			args := named.random(r)
+			err := named.Set(args)
+			c.resetArgs()
+			if err == nil {
+				return args
+			}
+		}
+		return nil
+	}
+
+	// Values that read the standard input when no arguments or a "-" argument are given, are given
+	// other arguments, such that neither the generation nor the parsing read the standard input.
+	stdin := readsStdin(c.args.value)
+
+	// Choose the values of the positional arguments.
+	var generators []func(*rand.Rand) string
+	values := predictedValues((*completer)(c).argsPredictor(nil), c.args.predict)
+	if stdin {
+		values = without(values, "-")
+	}
+	if len(values) > 0 {
+		generators = append(generators, func(r *rand.Rand) string { return values[r.Intn(len(values))] })
+	} else {
+		generators = append(generators, randomWord, randomInt)
+	}
+
+	// Try different number of arguments until one is accepted.
+	for _, n := range r.Perm(maxRandomArgs + 1) {
+		if stdin && n == 0 {
+			continue
+		}
+		for _, i := range r.Perm(len(generators)) {
+			args := make([]string, n)
+			for j := range args {
+				args[j] = generators[i](r)
+			}
+			err := c.args.value.Set(args)
+			c.resetArgs()
+			if err == nil {
+				return args
+			}
+		}
+	}
+	return nil
+}
+
+// readsStdin reports if a positional arguments value is or wraps a value that was returned by
+// `ArgsStdin`.
+func readsStdin(value ArgsValue) bool {
+	for {
+		if _, ok := value.(*argsStdin); ok {
+			return true
+		}
+		w, ok := value.(wrapper)
+		if !ok {
+			return false
+		}
+		value = w.unwrap()
+	}
+}
+
+// without returns the values that are not equal to the given value.
+func without(values []string, value string) []string {
+	var filtered []string
+	for _, v := range values {
+		if v != value {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+// random returns random values for named positional arguments.
+func (a *namedArgs) random(r *rand.Rand) []string {
+	var values []string
+	for _, arg := range a.args {
+		n := 1
+		switch arg.kind {
+		case argOptional:
+			n = r.Intn(2)
+		case argVariadic:
+			n = r.Intn(4)
+		}
+		if n == 0 {
+			// Following optional arguments can't be given.
+			break
+		}
+		for i := 0; i < n; i++ {
+			values = append(values, arg.random(r))
+		}
+	}
+	return values
+}
+
+// random returns a random value for a named positional argument.
+func (a *namedArg) random(r *rand.Rand) string {
+	if values := predictedValues(a.predict, a.predict); len(values) > 0 {
+		return values[r.Intn(len(values))]
+	}
+	return randomFlagValue(r, &flag.Flag{Name: a.name, Value: a.value, DefValue: a.value.String()})
+}
+
+// predictedValues returns the values that the given predictor predicts and are accepted by the
+// given checker.
+func predictedValues(p interface{}, checker interface{}) []string {
+	predictor, ok := p.(complete.Predictor)
+	if !ok || predictor == nil {
+		return nil
+	}
+	check, _ := checker.(interface{ Check(string) error })
+
+	var values []string
+	for _, value := range predictor.Predict("") {
+		if value == "" || (check != nil && check.Check(value) != nil) {
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func randomWord(r *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 1+r.Intn(8))
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+func randomInt(r *rand.Rand) string {
+	return strconv.Itoa(r.Intn(100))
+}
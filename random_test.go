@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/posener/complete/v2/predict"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomArgs(t *testing.T) {
+	t.Parallel()
+
+	f := func(seed int64) bool {
+		args := newTestCmd().RandomArgs(rand.New(rand.NewSource(seed)))
+		err := newTestCmd().ParseArgs(args...)
+		if err != nil {
+			t.Logf("%v: %v", args, err)
+		}
+		return err == nil
+	}
+	assert.NoError(t, quick.Check(f, nil))
+}
+
+func TestRandomArgs_argsFn(t *testing.T) {
+	t.Parallel()
+
+	root := New(OptErrorHandling(flag.ContinueOnError), OptOutput(ioutil.Discard))
+	root.Int("int", 0, "")
+	root.ArgsVar(ArgsFn(func(args []string) error {
+		if len(args) != 2 {
+			return assert.AnError
+		}
+		return nil
+	}), "[a] [b]", "")
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		args := root.RandomArgs(r)
+		assert.NoError(t, root.ParseArgs(args...), "args: %v", args)
+	}
+}
+
+func TestRandomArgs_commandWithSubCommandsAndArgs(t *testing.T) {
+	t.Parallel()
+
+	root := New(OptErrorHandling(flag.ContinueOnError), OptOutput(ioutil.Discard))
+	root.ArgsVar(&ArgsStr{}, "[arg...]", "")
+	sub := root.SubCommand("sub", "")
+	sub.ArgsVar(&ArgsStr{}, "[arg...]", "")
+
+	var rootInvoked, subInvoked int
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		args := root.RandomArgs(r)
+		require.NoError(t, root.ParseArgs(args...), "args: %v", args)
+		if root.Invoked() {
+			rootInvoked++
+		}
+		if sub.Invoked() {
+			subInvoked++
+		}
+	}
+	assert.Equal(t, 100, rootInvoked+subInvoked)
+	assert.NotZero(t, rootInvoked)
+	assert.NotZero(t, subInvoked)
+}
+
+func TestRandomArgs_stdin(t *testing.T) {
+	t.Parallel()
+
+	in := strings.NewReader("a\nb\n")
+	value := ArgsStdin(new(ArgsStr)).(*argsStdin)
+	value.in = in
+	root := New(OptErrorHandling(flag.ContinueOnError), OptOutput(ioutil.Discard))
+	root.ArgsVar(value, "[arg...]", "", predict.OptValues("-", "a"))
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 20; i++ {
+		args := root.RandomArgs(r)
+		require.NoError(t, root.ParseArgs(args...), "args: %v", args)
+		assert.NotEmpty(t, args[1:])
+		assert.NotContains(t, args[1:], "-")
+	}
+	assert.Equal(t, 4, in.Len(), "standard input must not be read")
+}
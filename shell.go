@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/posener/complete/v2"
+	"golang.org/x/term"
+)
+
+// Shell runs an interactive shell for the command tree. In the shell, the user types command lines
+// without the program name. Each line is split to words as in a POSIX shell, parsed as in
+// `ParseArgs`, and then the run function is called. The run function should act according to the
+// parsed command, similarly to what the program does after calling `Parse`. Errors from parsing and
+// from the run function are printed and the shell continues to the next line.
+//
+// When the standard input is a terminal, the shell supports line history and tab completion of
+// sub commands, flags and positional arguments. The shell has the built-in commands `help`, which
+// prints the usage of the command or of a given sub command, and `exit`. It returns when `exit` is
+// typed or when the input ends. When the standard input is not a terminal, the command lines are
+// read from it, hence positional arguments that read the standard input, such as `ArgsStdin`, would
+// consume the following command lines.
+//
+// Usage example:
+//
+// 	func main() {
+// 		root.Shell(func() error {
+// 			switch {
+// 			case sub1.Parsed():
+// 				...
+// 			}
+// 			return nil
+// 		})
+// 	}
+func (c *Cmd) Shell(run func() error) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return c.shell(os.Stdin, run)
+	}
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, filepath.Base(c.name)+"> ")
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return c.autoComplete(t, line, pos)
+	}
+	for {
+		// The terminal is in raw mode only while reading a line, such that output of the commands
+		// is printed normally.
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := t.ReadLine()
+		term.Restore(fd, state)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !c.shellLine(line, run) {
+			return nil
+		}
+	}
+}
+
+// shell runs the shell on input which is not a terminal.
+func (c *Cmd) shell(in io.Reader, run func() error) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !c.shellLine(scanner.Text(), run) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// shellLine runs a single line in the shell. It returns false if the shell should exit.
+func (c *Cmd) shellLine(line string, run func() error) bool {
+	words, err := splitWords(line, c.getenv)
+	if err == nil && len(words) == 0 {
+		return true
+	}
+	switch {
+	case err != nil:
+	case words[0].text == "exit":
+		return false
+	case words[0].text == "help":
+		err = c.parseHelp(texts(words[1:]))
+	default:
+		err = c.parseString(line)
+	}
+	if err == nil {
+		err = run()
+	}
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		c.printError(err)
+	}
+	return true
+}
+
+// parseHelp parses the help request of the given sub command path, which prints its usage.
+func (c *Cmd) parseHelp(path []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SubCmd.reset()
+	c.line = append([]string{c.name}, append(path, "-h")...)
+	_, err := c.parse(c.line)
+	return err
+}
+
+// autoComplete completes the word before the cursor position in the given line. When there are
+// several options, they are printed to the terminal.
+func (c *Cmd) autoComplete(t *term.Terminal, line string, pos int) (string, int, bool) {
+	prefix := line[:pos]
+	start := strings.LastIndexAny(prefix, " \t") + 1
+	word := prefix[start:]
+
+	options := c.completeLine(prefix)
+	switch len(options) {
+	case 0:
+		return line, pos, true
+	case 1:
+		completed := prefix[:start] + options[0] + " "
+		return completed + line[pos:], len(completed), true
+	}
+
+	if common := commonPrefix(options); len(common) > len(word) {
+		completed := prefix[:start] + common
+		return completed + line[pos:], len(completed), true
+	}
+	fmt.Fprintln(t, strings.Join(options, "  "))
+	return line, pos, true
+}
+
+// completeLine returns the completion options for the last word in a line of the shell. It uses
+// the completer of the command tree.
+func (c *Cmd) completeLine(line string) []string {
+	words := strings.Fields(line)
+	word := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		word = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	// Find the sub command.
+	var (
+		cmp   complete.Completer = (*completer)(c.SubCmd)
+		level                    = 0
+	)
+
+	// The help built-in takes a path of sub command names.
+	if len(words) > 0 && words[0] == "help" {
+		for _, w := range words[1:] {
+			if cmp = cmp.SubCmdGet(w); cmp == nil {
+				return nil
+			}
+		}
+		return filterPrefix(word, cmp.SubCmdList())
+	}
+	for _, w := range words {
+		sub := cmp.SubCmdGet(w)
+		if sub == nil {
+			break
+		}
+		cmp = sub
+		level++
+	}
+
+	// Complete sub commands. A command that has positional arguments also completes its flags and
+	// positional arguments.
+	hasArgs := (*SubCmd)(cmp.(*completer)).args != nil
+	if subs := cmp.SubCmdList(); len(subs) > 0 && (!hasArgs || (level == len(words) && !strings.HasPrefix(word, "-"))) {
+		if level < len(words) {
+			return nil
+		}
+		if level == 0 {
+			subs = append(subs, "exit", "help")
+		}
+		if p := cmp.(*completer).argsPredictor(nil); hasArgs && p != nil {
+			subs = append(subs, p.Predict(word)...)
+		}
+		return filterPrefix(word, subs)
+	}
+
+	// Complete flag values.
+	words = words[level:]
+	if i := strings.Index(word, "="); strings.HasPrefix(word, "-") && i > 0 {
+		options, _ := predictFlag(cmp, strings.TrimLeft(word[:i], "-"), word[i+1:])
+		for j := range options {
+			options[j] = word[:i+1] + options[j]
+		}
+		return options
+	}
+	if len(words) > 0 {
+		if last := words[len(words)-1]; strings.HasPrefix(last, "-") && !strings.Contains(last, "=") {
+			if options, ok := predictFlag(cmp, strings.TrimLeft(last, "-"), word); ok {
+				return options
+			}
+		}
+	}
+
+	// Complete flag names or positional arguments.
+	if strings.HasPrefix(word, "-") {
+		var flags []string
+		for _, name := range cmp.(*completer).flagNames() {
+			flags = append(flags, "-"+name)
+		}
+		return filterPrefix(word, flags)
+	}
+	if p := cmp.(*completer).argsPredictor(words); p != nil {
+		return filterPrefix(word, p.Predict(word))
+	}
+	return nil
+}
+
+// predictFlag returns the predicted values of a flag. It returns false if the flag is unknown or
+// does not expect a value.
+func predictFlag(cmp complete.Completer, name, prefix string) ([]string, bool) {
+	p := cmp.FlagGet(name)
+	if p == nil {
+		return nil, false
+	}
+	if b, ok := p.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return nil, false
+	}
+	return filterPrefix(prefix, p.Predict(prefix)), true
+}
+
+// filterPrefix returns the sorted non-empty options with the given prefix.
+func filterPrefix(prefix string, options []string) []string {
+	var filtered []string
+	for _, option := range options {
+		if option != "" && strings.HasPrefix(option, prefix) {
+			filtered = append(filtered, option)
+		}
+	}
+	sort.Strings(filtered)
+	return filtered
+}
+
+func commonPrefix(options []string) string {
+	common := options[0]
+	for _, option := range options[1:] {
+		for !strings.HasPrefix(option, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	return common
+}
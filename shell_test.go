@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShell(t *testing.T) {
+	t.Parallel()
+
+	root := newTestCmd()
+	var calls []string
+	run := func() error {
+		switch {
+		case root.sub11.Parsed():
+			calls = append(calls, "sub11:"+*root.sub11Flag+":"+strings.Join(*root.sub11Args, ","))
+		case root.sub2.Parsed():
+			calls = append(calls, "sub2:"+strings.Join(root.sub2Args, ","))
+		}
+		return nil
+	}
+
+	in := strings.NewReader(`
+sub1 sub1 -flag11 'hello world' a b
+sub2 arg
+sub1 sub1
+sub3
+sub2 'unterminated
+help sub2
+exit
+sub2 after-exit
+`)
+	require.NoError(t, root.shell(in, run))
+
+	assert.Equal(t, []string{"sub11:hello world:a,b", "sub2:arg", "sub11::"}, calls)
+	out := root.out.String()
+	assert.Contains(t, out, "invalid command: sub3\n")
+	assert.Contains(t, out, "unterminated ' quote\n")
+	assert.Contains(t, out, "Usage: cmd sub2 [flags] [arg]")
+}
+
+func TestShell_completeLine(t *testing.T) {
+	t.Parallel()
+
+	root := newTestCmd()
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "", want: []string{"exit", "help", "sub1", "sub2"}},
+		{line: "su", want: []string{"sub1", "sub2"}},
+		{line: "sub1 ", want: []string{"sub1", "sub2"}},
+		{line: "sub1 sub1 -f", want: []string{"-flag0", "-flag1", "-flag11"}},
+		{line: "sub1 sub1 -flag1 ", want: []string{"bar", "foo"}},
+		{line: "sub1 sub1 -flag1 f", want: []string{"foo"}},
+		{line: "sub1 sub1 -flag1=b", want: []string{"-flag1=bar"}},
+		{line: "sub1 sub1 -flag11 ", want: nil},
+		{line: "sub1 sub1 -flag0 ", want: []string{"one", "two"}},
+		{line: "sub2 ", want: []string{"one", "two"}},
+		{line: "sub2 -flag0 t", want: []string{"two"}},
+		{line: "sub3 ", want: nil},
+		{line: "help ", want: []string{"sub1", "sub2"}},
+		{line: "help sub1 s", want: []string{"sub1", "sub2"}},
+		{line: "help sub2 ", want: nil},
+		{line: "help sub3 ", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			assert.Equal(t, tt.want, root.completeLine(tt.line))
+		})
+	}
+}
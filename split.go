@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// LineError is an error in parsing a command line string, that points at the column in the line
+// where the error occurred. It is returned by `ParseString`.
+type LineError struct {
+	// Line is the parsed line.
+	Line string
+	// Column is the 1-based column of the error in the line.
+	Column int
+	// Err is the underlying error.
+	Err error
+
+	// width is the number of columns of the offending part of the line.
+	width int
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("column %d: %v", e.Column, e.Err)
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+func (e *LineError) usageError() {}
+
+// word is a word in a command line string.
+type word struct {
+	text string
+	// col is the 1-based column in which the word starts.
+	col int
+	// end is the 1-based column after the end of the word.
+	end int
+}
+
+// splitWords splits a line to words as in a POSIX shell. Words are separated by white spaces. A
+// backslash escapes the following character. Text in single quotes is taken literally, and in
+// double quotes a backslash escapes only the characters '"', '\' and '$'.
+//
+// If getenv is not nil, environment variables in the forms $NAME and ${NAME} are expanded using
+// it, unless they are in single quotes.
+func splitWords(line string, getenv func(string) string) ([]word, error) {
+	var (
+		words   []word
+		text    strings.Builder
+		start   = -1 // Start index of current word, or -1 if not in a word.
+		quote   byte
+		quoteAt int
+		escaped bool
+	)
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		if start < 0 && (escaped || !isSpace(ch)) {
+			start = i
+		}
+		switch {
+		case escaped:
+			if quote == '"' && ch != '"' && ch != '\\' && ch != '$' {
+				text.WriteByte('\\')
+			}
+			text.WriteByte(ch)
+			escaped = false
+		case ch == '\\' && quote != '\'':
+			escaped = true
+		case ch == '$' && quote != '\'' && getenv != nil:
+			name, n, err := envName(line[i+1:])
+			if err != nil {
+				return nil, &LineError{Line: line, Column: i + 1, Err: err}
+			}
+			if n == 0 {
+				text.WriteByte(ch)
+			} else {
+				text.WriteString(getenv(name))
+			}
+			i += n
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote != 0:
+			text.WriteByte(ch)
+		case ch == '\'' || ch == '"':
+			quote, quoteAt = ch, i
+		case isSpace(ch):
+			if start >= 0 {
+				words = append(words, word{text: text.String(), col: start + 1, end: i + 1})
+				text.Reset()
+				start = -1
+			}
+		default:
+			text.WriteByte(ch)
+		}
+	}
+	switch {
+	case escaped:
+		return nil, &LineError{Line: line, Column: len(line), Err: fmt.Errorf("unexpected end of line after backslash")}
+	case quote != 0:
+		return nil, &LineError{Line: line, Column: quoteAt + 1, Err: fmt.Errorf("unterminated %c quote", quote)}
+	}
+	if start >= 0 {
+		words = append(words, word{text: text.String(), col: start + 1, end: len(line) + 1})
+	}
+	return words, nil
+}
+
+// envName returns the name of an environment variable at the beginning of s, which follows a '$'
+// sign, and the number of bytes it takes in s. It returns 0 bytes if s does not start with a name.
+func envName(s string) (string, int, error) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unterminated ${")
+		}
+		return s[1:end], end + 1, nil
+	}
+	n := 0
+	for n < len(s) && (s[n] == '_' || isAlpha(s[n]) || (n > 0 && '0' <= s[n] && s[n] <= '9')) {
+		n++
+	}
+	return s[:n], n, nil
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n'
+}
+
+func isAlpha(ch byte) bool {
+	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z')
+}
+
+// texts returns the texts of the given words.
+func texts(words []word) []string {
+	s := make([]string, 0, len(words))
+	for _, w := range words {
+		s = append(s, w.text)
+	}
+	return s
+}
+
+// expandResponseFiles replaces arguments of the form @path, except the command name, with the
+// arguments in the file at the given path.
+func (c *Cmd) expandResponseFiles(args []string) ([]string, error) {
+	var expanded []string
+	for i, arg := range args {
+		if i == 0 || len(arg) < 2 || arg[0] != '@' {
+			expanded = append(expanded, arg)
+			continue
+		}
+		values, err := readResponseFile(arg[1:], c.responseFormat)
+		expanded = append(expanded, values...)
+		if err != nil {
+			return nil, &ArgsError{
+				Path:     c.name,
+				Arg:      arg,
+				Err:      fmt.Errorf("response file: %w", err),
+				position: position{fromEnd: len(args) - i},
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// readResponseFile returns the arguments in a response file.
+func readResponseFile(path string, format ResponseFormat) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == ResponseLines {
+		return readLines(bytes.NewReader(content))
+	}
+	words, err := splitWords(string(content), nil)
+	var lineErr *LineError
+	if errors.As(err, &lineErr) {
+		// Point at the line in the file, instead of at the column in the whole content.
+		before := string(content[:lineErr.Column-1])
+		line := strings.Count(before, "\n") + 1
+		column := len(before) - strings.LastIndex(before, "\n")
+		return nil, fmt.Errorf("line %d, column %d: %w", line, column, lineErr.Err)
+	}
+	return texts(words), err
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitWords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		line    string
+		want    []string
+		cols    []int
+		wantErr int
+	}{
+		{line: "", want: []string{}},
+		{line: "  ", want: []string{}},
+		{line: "a bb  c", want: []string{"a", "bb", "c"}, cols: []int{1, 3, 7}},
+		{line: " a\tb ", want: []string{"a", "b"}, cols: []int{2, 4}},
+		{line: `'a b' "c d"`, want: []string{"a b", "c d"}, cols: []int{1, 7}},
+		{line: `a' 'b`, want: []string{"a b"}},
+		{line: `''`, want: []string{""}},
+		{line: `a\ b`, want: []string{"a b"}},
+		{line: `\ a`, want: []string{" a"}},
+		{line: `'a\ b'`, want: []string{`a\ b`}},
+		{line: `"a\"b\$c\d"`, want: []string{`a"b$c\d`}},
+		{line: `"it's"`, want: []string{"it's"}},
+		{line: `$FOO`, want: []string{"$FOO"}},
+		{line: `'a`, wantErr: 1},
+		{line: `a "b`, wantErr: 3},
+		{line: `a\`, wantErr: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := splitWords(tt.line, nil)
+			if tt.wantErr > 0 {
+				var lineErr *LineError
+				if assert.True(t, errors.As(err, &lineErr)) {
+					assert.Equal(t, tt.wantErr, lineErr.Column)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, texts(got))
+			for i, col := range tt.cols {
+				assert.Equal(t, col, got[i].col)
+			}
+		})
+	}
+}
+
+func TestSplitWords_env(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"FOO": "foo", "BAR_1": "bar baz"}
+	getenv := func(name string) string { return env[name] }
+
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: `$FOO`, want: []string{"foo"}},
+		{line: `${FOO}x`, want: []string{"foox"}},
+		{line: `"$BAR_1" '$FOO'`, want: []string{"bar baz", "$FOO"}},
+		{line: `\$FOO $ $-`, want: []string{"$FOO", "$", "$-"}},
+		{line: `${FOO`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := splitWords(tt.line, getenv)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, texts(got))
+		})
+	}
+}
+
+func TestCmd_responseFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ids := filepath.Join(dir, "ids")
+	require.NoError(t, ioutil.WriteFile(ids, []byte("1\n2 '3 4'\n\n5\n"), 0644))
+	names := filepath.Join(dir, "names")
+	require.NoError(t, ioutil.WriteFile(names, []byte("a b.txt\nit's.txt\n\n c \n"), 0644))
+	bad := filepath.Join(dir, "bad")
+	require.NoError(t, ioutil.WriteFile(bad, []byte("1\n'2"), 0644))
+
+	newRoot := func(options ...optionRoot) (*Cmd, *ArgsStr) {
+		root := New(append(options, OptName("cmd"), OptOutput(ioutil.Discard), OptErrorHandling(flag.ContinueOnError))...)
+		root.Bool("flag", false, "")
+		var args ArgsStr
+		root.ArgsVar(&args, "[id...]", "")
+		return root, &args
+	}
+
+	t.Run("lines", func(t *testing.T) {
+		root, args := newRoot(OptResponseFiles(ResponseLines))
+		require.NoError(t, root.ParseArgs("cmd", "-flag", "0", "@"+names, "6", "@"))
+		assert.Equal(t, ArgsStr{"0", "a b.txt", "it's.txt", "c", "6", "@"}, *args)
+	})
+
+	t.Run("quoted", func(t *testing.T) {
+		root, args := newRoot(OptResponseFiles(ResponseQuoted))
+		require.NoError(t, root.ParseArgs("cmd", "-flag", "0", "@"+ids, "6", "@"))
+		assert.Equal(t, ArgsStr{"0", "1", "2", "3 4", "5", "6", "@"}, *args)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		root, args := newRoot()
+		require.NoError(t, root.ParseArgs("cmd", "@"+ids))
+		assert.Equal(t, ArgsStr{"@" + ids}, *args)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		root, _ := newRoot(OptResponseFiles(ResponseLines))
+		err := root.ParseArgs("cmd", "1", "@"+filepath.Join(dir, "nope"))
+		var e *ArgsError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "@"+filepath.Join(dir, "nope"), e.Arg)
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	t.Run("bad quoting", func(t *testing.T) {
+		var out bytes.Buffer
+		root := New(OptName("cmd"), OptOutput(&out), OptResponseFiles(ResponseQuoted), OptErrCaret(), OptExit(func(int) {}))
+		root.ArgsVar(&ArgsStr{}, "", "")
+		err := root.ParseArgs("cmd", "@"+bad)
+		var e *ArgsError
+		require.True(t, errors.As(err, &e))
+		assert.Equal(t, "@"+bad, e.Arg)
+		assert.False(t, errors.As(err, new(*LineError)))
+		assert.EqualError(t, err, "cmd: bad positional args: response file: line 2, column 1: unterminated ' quote")
+		// The caret points at the response file argument.
+		assert.True(t, strings.HasSuffix(out.String(), "cmd @"+bad+"\n    "+strings.Repeat("^", len(bad)+1)+"\n"), "got:\n%s", out.String())
+	})
+}